@@ -0,0 +1,272 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/indigo-web/client/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitOpenError is returned, when requests to the host are rejected by the circuit
+// breaker. It matches the ErrCircuitOpen, when checked with errors.Is
+type CircuitOpenError struct {
+	Host string
+	// RetryIn is the time left until probe requests are let through
+	RetryIn time.Duration
+}
+
+func (c *CircuitOpenError) Error() string {
+	return "circuit breaker is open for " + c.Host + ", retry in " + c.RetryIn.String()
+}
+
+func (c *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+type BreakerState uint8
+
+const (
+	// BreakerClosed lets all the requests through
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects all the requests until the cool-down is over
+	BreakerOpen
+	// BreakerHalfOpen lets a limited number of probe requests through. If they succeed,
+	// the breaker is closed, otherwise it's opened again
+	BreakerHalfOpen
+)
+
+func (b BreakerState) String() string {
+	switch b {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "BreakerState(" + strconv.Itoa(int(b)) + ")"
+	}
+}
+
+// BreakerSettings define, when the breaker trips and how it recovers. Connection errors
+// and 5xx responses are considered failures
+type BreakerSettings struct {
+	// ConsecutiveFailures trips the breaker after so many failures in a row. Zero
+	// disables the check
+	ConsecutiveFailures int
+	// FailureRate trips the breaker, if the share of failures within the Window reaches
+	// it. Zero disables the check
+	FailureRate float64
+	// MinRequests is the minimal number of requests within the Window, so the failure
+	// rate is considered representative
+	MinRequests int
+	// Window is the period, over which the failure rate is calculated
+	Window time.Duration
+	// CoolDown is the time, the breaker stays open for, before probe requests are let
+	// through
+	CoolDown time.Duration
+	// Probes is the number of successful probe requests, required to close the breaker
+	Probes int
+}
+
+func DefaultBreakerSettings() BreakerSettings {
+	return BreakerSettings{
+		ConsecutiveFailures: 5,
+		FailureRate:         0.5,
+		MinRequests:         20,
+		Window:              time.Minute,
+		CoolDown:            30 * time.Second,
+		Probes:              1,
+	}
+}
+
+// CircuitBreaker tracks failures per each host and rejects requests to hosts, which
+// seem to be down. It's safe to share a single breaker between multiple sessions and
+// pools. Hosts are identified by hostname:port, e.g. example.com:443, the same way as
+// by the rate limiter
+type CircuitBreaker struct {
+	mu            sync.Mutex
+	settings      BreakerSettings
+	circuits      map[string]*circuit
+	onStateChange func(host string, from, to BreakerState)
+}
+
+func NewCircuitBreaker(s BreakerSettings) *CircuitBreaker {
+	s.Probes = max(s.Probes, 1)
+
+	return &CircuitBreaker{
+		settings: s,
+		circuits: make(map[string]*circuit),
+	}
+}
+
+// OnStateChange sets the callback, called every time the state of a host changes. It's
+// called synchronously, so it must not block for long
+func (c *CircuitBreaker) OnStateChange(cb func(host string, from, to BreakerState)) *CircuitBreaker {
+	c.mu.Lock()
+	c.onStateChange = cb
+	c.mu.Unlock()
+
+	return c
+}
+
+// State returns the current state of the host
+func (c *CircuitBreaker) State(host string) BreakerState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if circ, found := c.circuits[host]; found {
+		return circ.state
+	}
+
+	return BreakerClosed
+}
+
+// Allow reports, whether the request to the host may be sent. If it returns nil, Done
+// MUST be called after the request is completed
+func (c *CircuitBreaker) Allow(host string) error {
+	c.mu.Lock()
+	circ := c.circuit(host)
+	now := time.Now()
+	from := circ.state
+
+	switch circ.state {
+	case BreakerOpen:
+		if left := c.settings.CoolDown - now.Sub(circ.openedAt); left > 0 {
+			c.mu.Unlock()
+			return &CircuitOpenError{Host: host, RetryIn: left}
+		}
+
+		circ.halfOpen()
+		fallthrough
+	case BreakerHalfOpen:
+		if circ.probes >= c.settings.Probes {
+			c.mu.Unlock()
+			return &CircuitOpenError{Host: host}
+		}
+
+		circ.probes++
+	}
+
+	c.unlockAndNotify(host, from, circ.state)
+
+	return nil
+}
+
+// Done reports the result of the request to the host. Errors, caused by the client
+// itself, e.g. the rate limiter or the context, don't affect the breaker
+func (c *CircuitBreaker) Done(host string, resp *http.Response, err error) {
+	c.mu.Lock()
+	circ := c.circuit(host)
+	from := circ.state
+
+	switch {
+	case isNeutral(err):
+		if circ.state == BreakerHalfOpen {
+			circ.probes--
+		}
+	case err != nil || resp.Code >= 500:
+		circ.failure(c.settings, time.Now())
+	default:
+		circ.success(c.settings, time.Now())
+	}
+
+	c.unlockAndNotify(host, from, circ.state)
+}
+
+func (c *CircuitBreaker) circuit(host string) *circuit {
+	circ, found := c.circuits[host]
+	if !found {
+		circ = new(circuit)
+		c.circuits[host] = circ
+	}
+
+	return circ
+}
+
+func (c *CircuitBreaker) unlockAndNotify(host string, from, to BreakerState) {
+	cb := c.onStateChange
+	c.mu.Unlock()
+
+	if from != to && cb != nil {
+		cb(host, from, to)
+	}
+}
+
+// isNeutral reports, whether the error says nothing about the health of the host
+func isNeutral(err error) bool {
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrCircuitOpen) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+type circuit struct {
+	state       BreakerState
+	consecutive int
+	requests    int
+	failures    int
+	windowStart time.Time
+	openedAt    time.Time
+	probes      int
+	succeeded   int
+}
+
+func (c *circuit) failure(s BreakerSettings, now time.Time) {
+	if c.state == BreakerHalfOpen {
+		c.open(now)
+		return
+	}
+
+	c.record(s, now)
+	c.consecutive++
+	c.failures++
+
+	switch {
+	case s.ConsecutiveFailures > 0 && c.consecutive >= s.ConsecutiveFailures,
+		s.FailureRate > 0 && c.requests >= s.MinRequests &&
+			float64(c.failures)/float64(c.requests) >= s.FailureRate:
+		c.open(now)
+	}
+}
+
+func (c *circuit) success(s BreakerSettings, now time.Time) {
+	if c.state == BreakerHalfOpen {
+		if c.succeeded++; c.succeeded >= s.Probes {
+			c.close()
+		}
+
+		return
+	}
+
+	c.record(s, now)
+	c.consecutive = 0
+}
+
+// record counts the request within the current window, starting a new one if needed
+func (c *circuit) record(s BreakerSettings, now time.Time) {
+	if now.Sub(c.windowStart) >= s.Window {
+		c.windowStart = now
+		c.requests = 0
+		c.failures = 0
+	}
+
+	c.requests++
+}
+
+func (c *circuit) open(now time.Time) {
+	c.state = BreakerOpen
+	c.openedAt = now
+}
+
+func (c *circuit) halfOpen() {
+	c.state = BreakerHalfOpen
+	c.probes = 0
+	c.succeeded = 0
+}
+
+func (c *circuit) close() {
+	*c = circuit{}
+}
@@ -0,0 +1,164 @@
+package client
+
+import (
+	"github.com/indigo-web/client/http"
+	"github.com/indigo-web/client/settings"
+	"github.com/stretchr/testify/require"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	ok := &http.Response{Code: 200}
+	failed := &http.Response{Code: 503}
+
+	newBreaker := func() (*CircuitBreaker, *[]string) {
+		var transitions []string
+		breaker := NewCircuitBreaker(BreakerSettings{
+			ConsecutiveFailures: 2,
+			CoolDown:            10 * time.Millisecond,
+			Probes:              1,
+		}).OnStateChange(func(host string, from, to BreakerState) {
+			transitions = append(transitions, host+": "+from.String()+" -> "+to.String())
+		})
+
+		return breaker, &transitions
+	}
+
+	t.Run("consecutive failures", func(t *testing.T) {
+		breaker, transitions := newBreaker()
+		for _, resp := range []*http.Response{failed, ok, failed} {
+			require.NoError(t, breaker.Allow("a"))
+			breaker.Done("a", resp, nil)
+		}
+
+		require.Equal(t, BreakerClosed, breaker.State("a"))
+		require.NoError(t, breaker.Allow("a"))
+		breaker.Done("a", nil, io.ErrUnexpectedEOF)
+		require.Equal(t, BreakerOpen, breaker.State("a"))
+
+		err := breaker.Allow("a")
+		require.ErrorIs(t, err, ErrCircuitOpen)
+		var openErr *CircuitOpenError
+		require.ErrorAs(t, err, &openErr)
+		require.Equal(t, "a", openErr.Host)
+		require.NoError(t, breaker.Allow("b"))
+		require.Equal(t, []string{"a: closed -> open"}, *transitions)
+	})
+
+	t.Run("half-open", func(t *testing.T) {
+		breaker, transitions := newBreaker()
+		for i := 0; i < 2; i++ {
+			require.NoError(t, breaker.Allow("a"))
+			breaker.Done("a", failed, nil)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		require.NoError(t, breaker.Allow("a"))
+		// only a single probe is allowed at once
+		require.ErrorIs(t, breaker.Allow("a"), ErrCircuitOpen)
+		breaker.Done("a", failed, nil)
+		require.Equal(t, BreakerOpen, breaker.State("a"))
+
+		time.Sleep(10 * time.Millisecond)
+		require.NoError(t, breaker.Allow("a"))
+		breaker.Done("a", ok, nil)
+		require.Equal(t, BreakerClosed, breaker.State("a"))
+
+		require.Equal(t, []string{
+			"a: closed -> open",
+			"a: open -> half-open",
+			"a: half-open -> open",
+			"a: open -> half-open",
+			"a: half-open -> closed",
+		}, *transitions)
+	})
+
+	t.Run("failure rate", func(t *testing.T) {
+		breaker := NewCircuitBreaker(BreakerSettings{
+			FailureRate: 0.5,
+			MinRequests: 4,
+			Window:      time.Minute,
+			CoolDown:    time.Minute,
+		})
+
+		for _, resp := range []*http.Response{ok, failed, ok} {
+			require.NoError(t, breaker.Allow("a"))
+			breaker.Done("a", resp, nil)
+		}
+
+		require.Equal(t, BreakerClosed, breaker.State("a"))
+		require.NoError(t, breaker.Allow("a"))
+		breaker.Done("a", failed, nil)
+		require.Equal(t, BreakerOpen, breaker.State("a"))
+	})
+
+	t.Run("neutral errors", func(t *testing.T) {
+		breaker, _ := newBreaker()
+		for i := 0; i < 3; i++ {
+			require.NoError(t, breaker.Allow("a"))
+			breaker.Done("a", nil, ErrRateLimited)
+		}
+
+		require.Equal(t, BreakerClosed, breaker.State("a"))
+	})
+
+	t.Run("pool", func(t *testing.T) {
+		server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			w.WriteHeader(nethttp.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		pool := NewPool(settings.Default(), nil)
+		defer pool.Close()
+		breaker, _ := newBreaker()
+		breaker.settings.CoolDown = time.Minute
+		pool.SetCircuitBreaker(breaker)
+
+		for i := 0; i < 2; i++ {
+			resp, err := pool.Get(server.URL)
+			require.NoError(t, err)
+			require.Equal(t, 500, int(resp.Code))
+			require.NoError(t, resp.Body.Close())
+		}
+
+		_, err := pool.Get(server.URL)
+		require.ErrorIs(t, err, ErrCircuitOpen)
+		u, err := url.Parse(server.URL)
+		require.NoError(t, err)
+		require.Equal(t, BreakerOpen, breaker.State(u.Host))
+	})
+
+	t.Run("shared by session and pool", func(t *testing.T) {
+		server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			w.WriteHeader(nethttp.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		host := server.Listener.Addr().String()
+		breaker, _ := newBreaker()
+		breaker.settings.CoolDown = time.Minute
+		session, err := NewSession(host)
+		require.NoError(t, err)
+		defer session.Close()
+		session.SetCircuitBreaker(breaker)
+		pool := NewPool(settings.Default(), nil)
+		defer pool.Close()
+		pool.SetCircuitBreaker(breaker)
+
+		resp, err := session.Send(session.GET("/"))
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		resp, err = pool.Get(server.URL)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		require.Equal(t, BreakerOpen, breaker.State(host))
+		_, err = session.Send(session.GET("/"))
+		require.ErrorIs(t, err, ErrCircuitOpen)
+	})
+}
@@ -1,14 +1,21 @@
 package client
 
 import (
-	"github.com/indigo-web/chunkedbody"
+	"context"
+	"crypto/tls"
 	"github.com/indigo-web/client/http"
+	"github.com/indigo-web/client/http/codec"
+	"github.com/indigo-web/client/http/coding"
 	"github.com/indigo-web/client/http/headers"
 	"github.com/indigo-web/client/http/method"
+	"github.com/indigo-web/client/http/status"
 	"github.com/indigo-web/client/internal/parser"
 	"github.com/indigo-web/client/internal/parser/http1"
 	"github.com/indigo-web/client/internal/render"
 	"github.com/indigo-web/client/internal/tcp"
+	"github.com/indigo-web/client/metrics"
+	"github.com/indigo-web/client/settings"
+	"github.com/indigo-web/client/trace"
 	"github.com/indigo-web/utils/buffer"
 	"net"
 	"time"
@@ -22,56 +29,216 @@ const (
 	respLineBuffMax     = 1024
 	headersBuffInitial  = 2 * 1024
 	headersBuffMax      = 32 * 1024
+	trailerBuffInitial  = 0
+	trailerBuffMax      = 8 * 1024
 	renderBuffDefault   = 2 * 1024
 	preAllocHeaders     = 10
 )
 
 type Session struct {
+	host string
+	// addr is hostname:port of the server. It identifies the server in the rate limiter,
+	// the circuit breaker and metrics
+	addr     string
 	client   tcp.Client
 	parser   parser.Parser
 	renderer render.Renderer
 	request  *http.Request
 	response *http.Response
+	codings  coding.Manager
+	codecs   codec.Manager
+	defaults *headers.Headers
+	settings settings.Settings
+	// dial establishes a new connection to the same host. If nil, the session can't
+	// reconnect, so connection errors aren't retried
+	dial        func(ctx context.Context, t *trace.ClientTrace) (net.Conn, error)
+	limiter     *RateLimiter
+	breaker     *CircuitBreaker
+	middlewares []Middleware
+	transport   RoundTripper
+	trace       *trace.ClientTrace
+	metrics     metrics.Metrics
+	// reused reports, whether the current connection has already served a request
+	reused bool
+	// responded reports, whether any byte of the current response has been received
+	responded bool
 }
 
+// NewSession connects to the host and returns a session with default settings
 func NewSession(host string) (*Session, error) {
-	conn, err := net.Dial("tcp", host)
+	return NewSessionWithSettings(host, settings.Default())
+}
+
+func NewSessionWithSettings(host string, s settings.Settings) (*Session, error) {
+	connect := func(ctx context.Context, t *trace.ClientTrace) (net.Conn, error) {
+		return dial(ctx, host, nil, t)
+	}
+
+	conn, err := connect(context.Background(), nil)
 	if err != nil {
 		return nil, err
 	}
 
+	session := NewSessionFromConn(conn, host, s)
+	session.dial = connect
+
+	return session, nil
+}
+
+// NewTLSSession connects to the host over TLS and returns a session with default
+// settings. If config is nil, the default one is used
+func NewTLSSession(host string, config *tls.Config) (*Session, error) {
+	return NewTLSSessionWithSettings(host, config, settings.Default())
+}
+
+func NewTLSSessionWithSettings(host string, config *tls.Config, s settings.Settings) (*Session, error) {
+	if config == nil {
+		config = new(tls.Config)
+	}
+
+	connect := func(ctx context.Context, t *trace.ClientTrace) (net.Conn, error) {
+		return dial(ctx, host, config, t)
+	}
+
+	conn, err := connect(context.Background(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	session := NewSessionFromConn(conn, host, s)
+	session.dial = connect
+
+	return session, nil
+}
+
+// NewSessionFromConn returns a session over already established connection. The host
+// is used as a value for the Host header. As the session doesn't know, how to establish
+// the connection again, failed connections aren't retried
+func NewSessionFromConn(conn net.Conn, host string, s settings.Settings) *Session {
 	respLineBuff := buffer.NewBuffer[byte](respLineBuffInitial, respLineBuffMax)
 	headersBuff := buffer.NewBuffer[byte](headersBuffInitial, headersBuffMax)
+	trailerBuff := buffer.NewBuffer[byte](trailerBuffInitial, trailerBuffMax)
 	buff := make([]byte, tcpBuffSize)
 	client := tcp.NewClient(conn, readTimeout, writeTimeout, buff)
-	bodyReader := http1.NewBody(client, chunkedbody.NewParser(chunkedbody.DefaultSettings()))
-	resp := http.NewResponse(bodyReader)
+	bodyReader := http1.NewBody(client, http1.NewChunkedParser(*trailerBuff, s.Body.MaxChunkSize), s.Body)
+	codings := coding.NewDefaultManager()
+	codecs := codec.NewDefaultManager()
+	defaults := headers.NewHeaders()
+	resp := http.NewResponse(bodyReader, codings, codecs, s.Body)
 	renderBuff := make([]byte, 0, renderBuffDefault)
 
-	return &Session{
+	session := &Session{
+		host:     host,
+		addr:     host,
 		client:   client,
 		parser:   http1.NewParser(resp, *respLineBuff, *headersBuff),
-		renderer: render.NewRenderer(client, renderBuff),
+		renderer: render.NewRenderer(client, host, defaults, renderBuff, codings, s.Request),
 		request:  http.NewRequest(headers.NewPreallocHeaders(preAllocHeaders)),
 		response: resp,
-	}, nil
+		codings:  codings,
+		codecs:   codecs,
+		defaults: defaults,
+		settings: s,
+	}
+	session.transport = RoundTripperFunc(session.roundTrip)
+
+	return session
 }
 
+// Send sends the request and returns the response. If retries are enabled by the
+// settings, failed requests are repeated according to the policy
 func (s *Session) Send(request *http.Request) (*http.Response, error) {
-	if err := s.response.Body.Reset(); err != nil {
+	if err := request.Error(); err != nil {
 		return nil, err
 	}
 
-	if err := s.renderer.Send(request); err != nil {
+	return s.transport.RoundTrip(request)
+}
+
+// Use adds middlewares, wrapping every Send of the session. They see the request before
+// it's rendered, and the response right after its headers are parsed. Middlewares are
+// called in the order they were added, and wrap the circuit breaker and retries
+func (s *Session) Use(middlewares ...Middleware) {
+	s.middlewares = append(s.middlewares, middlewares...)
+	s.transport = chain(RoundTripperFunc(s.roundTrip), s.middlewares)
+}
+
+func (s *Session) roundTrip(request *http.Request) (*http.Response, error) {
+	if s.breaker == nil {
+		return s.sendWithRetry(request)
+	}
+
+	if err := s.breaker.Allow(s.addr); err != nil {
 		return nil, err
 	}
 
+	resp, err := s.sendWithRetry(request)
+	s.breaker.Done(s.addr, resp, err)
+
+	return resp, err
+}
+
+func (s *Session) send(request *http.Request) (*http.Response, error) {
+	if s.limiter != nil {
+		if err := s.limiter.Wait(request.Context(), s.addr); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.response.Body.Reset(); err != nil {
+		// the rest of the previous response can't be skipped, e.g. it's too large or
+		// malformed, so the connection is unusable anymore
+		if s.dial == nil {
+			return nil, err
+		}
+
+		if err = s.reconnect(request); err != nil {
+			return nil, err
+		}
+	}
+
+	s.response.Clear()
+	s.parser.Release()
+
+	t := s.traceOf(request)
+	if t != nil && t.GotConn != nil {
+		t.GotConn(s.reused)
+	}
+
+	s.reused = true
+	s.responded = false
+
+	resp, err := s.exchange(request, t, time.Now())
+	if err != nil && s.metrics != nil {
+		s.metrics.Failure(s.addr, err)
+	}
+
+	return resp, err
+}
+
+// exchange writes the request and reads the response headers. The start is the time,
+// the durations reported to metrics are counted from
+func (s *Session) exchange(request *http.Request, t *trace.ClientTrace, start time.Time) (*http.Response, error) {
+	if err := s.renderer.Send(request, t); err != nil {
+		return nil, err
+	}
+
+	var firstByte time.Time
+
 	for {
 		data, err := s.client.Read()
 		if err != nil {
 			return nil, err
 		}
 
+		if firstByte.IsZero() {
+			firstByte = time.Now()
+			s.responded = true
+			if t != nil && t.GotFirstResponseByte != nil {
+				t.GotFirstResponseByte()
+			}
+		}
+
 		headersCompleted, rest, err := s.parser.Parse(data)
 		if err != nil {
 			// TODO: we should be more error-tolerant. Keep reading till the end (if the error isn't too hard)
@@ -81,45 +248,195 @@ func (s *Session) Send(request *http.Request) (*http.Response, error) {
 		s.client.Unread(rest)
 
 		if headersCompleted {
-			s.response.Body.Init(s.response)
+			if s.metrics != nil {
+				s.metrics.Response(s.addr, s.response.Code, firstByte.Sub(start))
+			}
+
+			s.onResponse()
+			s.initBody(request, t, start)
 
 			return s.response, nil
 		}
 	}
 }
 
+// initBody prepares the body of the parsed response to be read
+func (s *Session) initBody(request *http.Request, t *trace.ClientTrace, start time.Time) {
+	if t != nil && t.GotHeaders != nil {
+		t.GotHeaders(s.response)
+	}
+
+	onDone := s.onBodyDone(t, start)
+
+	if !hasBody(request.Method, s.response.Code) {
+		s.response.Body.InitEmpty(s.response)
+
+		if onDone != nil {
+			onDone(nil)
+		}
+
+		return
+	}
+
+	s.response.Body.Init(s.response)
+
+	if onDone != nil {
+		s.response.Body.OnDone(onDone)
+	}
+}
+
+// onBodyDone returns the callback for the end of the response body, or nil if neither
+// the trace nor metrics need it
+func (s *Session) onBodyDone(t *trace.ClientTrace, start time.Time) func(error) {
+	var traced func(error)
+	if t != nil {
+		traced = t.BodyDone
+	}
+
+	if s.metrics == nil {
+		return traced
+	}
+
+	host, m := s.addr, s.metrics
+
+	return func(err error) {
+		if err == nil {
+			m.Completed(host, time.Since(start))
+		}
+
+		if traced != nil {
+			traced(err)
+		}
+	}
+}
+
+// traceOf returns the trace of the request context, falling back to the session's one
+func (s *Session) traceOf(request *http.Request) *trace.ClientTrace {
+	if t := trace.ContextClientTrace(request.Context()); t != nil {
+		return t
+	}
+
+	return s.trace
+}
+
+// onResponse is called right after the response headers are parsed
+func (s *Session) onResponse() {
+	switch s.response.Code {
+	case status.TooManyRequests, status.ServiceUnavailable:
+		if delay, ok := s.response.RetryAfter(); ok && s.limiter != nil {
+			s.limiter.Pause(s.addr, delay)
+		}
+	}
+}
+
+// Close closes the connection. The session must not be used after that
+func (s *Session) Close() error {
+	_ = s.request.Close()
+	return s.client.Close()
+}
+
+// Codings returns the coding manager, used to decode response bodies. Custom codings
+// may be registered there, and will be automatically advertised via Accept-Encoding,
+// unless the request has it set explicitly. If the server responds with a coding,
+// that isn't registered, reading the body returns *coding.UnsupportedError
+func (s *Session) Codings() coding.Manager {
+	return s.codings
+}
+
+// Codecs returns the codec manager, used by http.Body.Decode. Codecs for other content
+// types, e.g. codec.XML, may be registered there
+func (s *Session) Codecs() codec.Manager {
+	return s.codecs
+}
+
+// SetRateLimiter sets the limiter, every request (including retries) must pass through.
+// The same limiter may be shared between multiple sessions and pools. Nil disables it
+func (s *Session) SetRateLimiter(limiter *RateLimiter) {
+	s.limiter = limiter
+}
+
+// SetCircuitBreaker sets the breaker, which rejects requests while the host seems to
+// be down. The whole Send, including retries, counts as a single request. Nil disables it
+func (s *Session) SetCircuitBreaker(breaker *CircuitBreaker) {
+	s.breaker = breaker
+}
+
+// SetTrace sets the trace, every request reports its stages to, unless its context
+// carries one (see trace.WithClientTrace). As the session is already connected by the
+// time, only reconnects report dialing. Nil disables it
+func (s *Session) SetTrace(t *trace.ClientTrace) {
+	s.trace = t
+}
+
+// SetMetrics sets the metrics, the session reports its requests and traffic to. Nil
+// disables it
+func (s *Session) SetMetrics(m metrics.Metrics) {
+	s.metrics = m
+	s.client.SetMetrics(m, s.addr)
+}
+
+// DefaultHeaders returns headers, which are added to every request of the session.
+// Headers of the request itself override the defaults with the same key, and
+// http.Request.WithoutHeader prevents them from being added at all
+func (s *Session) DefaultHeaders() *headers.Headers {
+	return s.defaults
+}
+
+// SetCompression sets the coding, all the request bodies will be compressed with, unless
+// overridden by http.Request.WithCompression. Empty token disables the compression
+func (s *Session) SetCompression(token coding.Token) {
+	s.renderer.SetCompression(token)
+}
+
+// PreferEncodings makes the codings to be advertised in Accept-Encoding with higher
+// q-values, in the passed order. Accept-Encoding, set manually, isn't affected
+func (s *Session) PreferEncodings(tokens ...coding.Token) {
+	s.renderer.SetPreferredEncodings(tokens)
+}
+
 func (s *Session) GET(path string) *http.Request {
-	return s.request.WithMethod(method.GET).WithPath(path)
+	return s.request.WithClear().WithMethod(method.GET).WithPath(path)
 }
 
 func (s *Session) HEAD(path string) *http.Request {
-	return s.request.WithMethod(method.HEAD).WithPath(path)
+	return s.request.WithClear().WithMethod(method.HEAD).WithPath(path)
 }
 
 func (s *Session) POST(path string) *http.Request {
-	return s.request.WithMethod(method.POST).WithPath(path)
+	return s.request.WithClear().WithMethod(method.POST).WithPath(path)
 }
 
 func (s *Session) PUT(path string) *http.Request {
-	return s.request.WithMethod(method.PUT).WithPath(path)
+	return s.request.WithClear().WithMethod(method.PUT).WithPath(path)
 }
 
 func (s *Session) DELETE(path string) *http.Request {
-	return s.request.WithMethod(method.DELETE).WithPath(path)
+	return s.request.WithClear().WithMethod(method.DELETE).WithPath(path)
 }
 
 func (s *Session) CONNECT(path string) *http.Request {
-	return s.request.WithMethod(method.CONNECT).WithPath(path)
+	return s.request.WithClear().WithMethod(method.CONNECT).WithPath(path)
 }
 
 func (s *Session) OPTIONS(path string) *http.Request {
-	return s.request.WithMethod(method.OPTIONS).WithPath(path)
+	return s.request.WithClear().WithMethod(method.OPTIONS).WithPath(path)
 }
 
 func (s *Session) TRACE(path string) *http.Request {
-	return s.request.WithMethod(method.TRACE).WithPath(path)
+	return s.request.WithClear().WithMethod(method.TRACE).WithPath(path)
 }
 
 func (s *Session) PATCH(path string) *http.Request {
-	return s.request.WithMethod(method.PATCH).WithPath(path)
+	return s.request.WithClear().WithMethod(method.PATCH).WithPath(path)
+}
+
+// hasBody reports, whether the response may have a body. Responses to HEAD requests,
+// as well as 1xx, 204 and 304 responses, never have one, even if Content-Length is set
+func hasBody(m method.Method, code status.Code) bool {
+	switch {
+	case m == method.HEAD, code >= 100 && code < 200, code == status.NoContent, code == status.NotModified:
+		return false
+	}
+
+	return true
 }
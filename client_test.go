@@ -0,0 +1,64 @@
+package client
+
+import (
+	"crypto/tls"
+	"github.com/indigo-web/client/http/status"
+	"github.com/indigo-web/client/settings"
+	"github.com/stretchr/testify/require"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSession(t *testing.T) {
+	t.Run("after too large body", func(t *testing.T) {
+		server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			if r.URL.Path == "/large" {
+				_, _ = w.Write([]byte(strings.Repeat("a", 64)))
+				return
+			}
+
+			_, _ = w.Write([]byte("hello"))
+		}))
+		defer server.Close()
+
+		s := settings.Default()
+		s.Body.MaxSize = 32
+		session, err := NewSessionWithSettings(server.Listener.Addr().String(), s)
+		require.NoError(t, err)
+		defer session.Close()
+
+		resp, err := session.Send(session.GET("/large"))
+		require.NoError(t, err)
+		_, err = resp.Body.Full()
+		require.ErrorIs(t, err, status.ErrBodyTooLarge)
+
+		for i := 0; i < 2; i++ {
+			resp, err = session.Send(session.GET("/"))
+			require.NoError(t, err)
+			body, err := resp.Body.Full()
+			require.NoError(t, err)
+			require.Equal(t, "hello", string(body))
+		}
+	})
+
+	t.Run("tls with settings", func(t *testing.T) {
+		server := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			_, _ = w.Write([]byte(strings.Repeat("a", 64)))
+		}))
+		defer server.Close()
+
+		s := settings.Default()
+		s.Body.MaxSize = 32
+		config := &tls.Config{InsecureSkipVerify: true}
+		session, err := NewTLSSessionWithSettings(server.Listener.Addr().String(), config, s)
+		require.NoError(t, err)
+		defer session.Close()
+
+		resp, err := session.Send(session.GET("/"))
+		require.NoError(t, err)
+		_, err = resp.Body.Full()
+		require.ErrorIs(t, err, status.ErrBodyTooLarge)
+	})
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/indigo-web/client/trace"
+	"net"
+)
+
+// dial connects to the address. If config isn't nil, the TLS handshake is performed as
+// well. Each step is reported to the trace, which may be nil. When the trace watches for
+// DNS, the host is resolved separately, and the addresses are tried one by one
+func dial(ctx context.Context, address string, config *tls.Config, t *trace.ClientTrace) (net.Conn, error) {
+	hostname, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := resolve(ctx, address, t)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		dialer net.Dialer
+		conn   net.Conn
+	)
+
+	for _, addr := range addrs {
+		if t != nil && t.ConnectStart != nil {
+			t.ConnectStart("tcp", addr)
+		}
+
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+
+		if t != nil && t.ConnectDone != nil {
+			t.ConnectDone("tcp", addr, err)
+		}
+
+		if err == nil {
+			break
+		}
+	}
+
+	if err != nil || config == nil {
+		return conn, err
+	}
+
+	config = config.Clone()
+	if len(config.ServerName) == 0 {
+		config.ServerName = hostname
+	}
+
+	if t != nil && t.TLSHandshakeStart != nil {
+		t.TLSHandshakeStart()
+	}
+
+	tlsConn := tls.Client(conn, config)
+	err = tlsConn.HandshakeContext(ctx)
+
+	if t != nil && t.TLSHandshakeDone != nil {
+		t.TLSHandshakeDone(tlsConn.ConnectionState(), err)
+	}
+
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+// resolve returns addresses to dial. Unless the trace watches for DNS, the address is
+// returned as is, so the resolution is left to the dialer
+func resolve(ctx context.Context, address string, t *trace.ClientTrace) ([]string, error) {
+	if t == nil || (t.DNSStart == nil && t.DNSDone == nil) {
+		return []string{address}, nil
+	}
+
+	host, port, _ := net.SplitHostPort(address)
+	if net.ParseIP(host) != nil {
+		return []string{address}, nil
+	}
+
+	if t.DNSStart != nil {
+		t.DNSStart(host)
+	}
+
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+
+	if t.DNSDone != nil {
+		t.DNSDone(ips, err)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.JoinHostPort(ip.String(), port)
+	}
+
+	return addrs, nil
+}
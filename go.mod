@@ -1,10 +1,14 @@
 module github.com/indigo-web/client
 
-go 1.20
+go 1.22
+
+require (
+	github.com/andybalholm/brotli v1.1.0
+	github.com/klauspost/compress v1.18.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/indigo-web/chunkedbody v0.1.0 // indirect
 	github.com/indigo-web/iter v0.0.4 // indirect
 	github.com/indigo-web/utils v0.4.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
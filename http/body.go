@@ -1,6 +1,12 @@
 package http
 
 import (
+	"github.com/indigo-web/client/http/codec"
+	"github.com/indigo-web/client/http/coding"
+	"github.com/indigo-web/client/http/query"
+	"github.com/indigo-web/client/http/status"
+	"github.com/indigo-web/client/settings"
+	"github.com/indigo-web/utils/strcomp"
 	"github.com/indigo-web/utils/unreader"
 	"io"
 )
@@ -17,19 +23,42 @@ var _ io.Reader = &Body{}
 
 type Body struct {
 	reader        BodyReader
+	codings       coding.Manager
+	codecs        codec.Manager
+	settings      settings.Body
 	unreader      unreader.Unreader
 	encoding      Encoding
 	contentLength int
+	contentType   string
 	bodyBuff      []byte
+	decodeBuff    []byte
+	source        pieceReader
+	decoders      []io.ReadCloser
+	decoder       io.Reader
+	decodedSize   int64
+	raw, decoded  bool
+	empty         bool
+	onClose       func(error)
+	onDone        func(error)
 }
 
-func NewBody(reader BodyReader) *Body {
+func NewBody(
+	reader BodyReader, codings coding.Manager, codecs codec.Manager, s settings.Body,
+) *Body {
 	return &Body{
-		reader: reader,
+		reader:   reader,
+		codings:  codings,
+		codecs:   codecs,
+		settings: s,
+		source:   pieceReader{reader: reader},
 	}
 }
 
-const chunkedTE = -1
+const (
+	chunkedTE = -1
+	// decodeBuffSize is a size of the buffer, the decoded data is read into
+	decodeBuffSize = 4 * 1024
+)
 
 // Init is a system method, that MUST not be called, otherwise connection may get
 // stuck, leading to hanging connection (until read-timeout won't be exceeded)
@@ -38,12 +67,42 @@ func (b *Body) Init(resp *Response) {
 	b.unreader.Reset()
 	b.encoding = resp.Encoding
 	b.contentLength = resp.ContentLength
+	b.contentType = resp.ContentType
+	b.raw = false
+	b.decoded = false
+	b.decodedSize = 0
+	b.empty = false
+	b.onDone = nil
+	b.source.pending = nil
+	b.closeDecoders()
 
 	if resp.Encoding.Chunked {
 		b.contentLength = chunkedTE
 	}
 }
 
+// InitEmpty is a system method, same as Init, but for responses, which never have a
+// body, regardless of their headers. For example, responses to HEAD requests
+func (b *Body) InitEmpty(resp *Response) {
+	b.Init(resp)
+	b.empty = true
+	b.contentLength = 0
+}
+
+// Raw disables decoding of the body, so it'll be returned exactly as it was sent,
+// compressed or whatsoever. Must be called before the body is read, otherwise the
+// behaviour is undefined
+func (b *Body) Raw() *Body {
+	b.raw = true
+	return b
+}
+
+// Decoded reports, whether the body is decoded while being read. This is so, if it has
+// a Content-Encoding and Raw wasn't called
+func (b *Body) Decoded() bool {
+	return !b.raw && b.isEncoded()
+}
+
 // Full returns the whole body at once
 //
 // WARNING: returned slice is an underlying buffer, that will be re-written during the
@@ -54,21 +113,45 @@ func (b *Body) Full() ([]byte, error) {
 	// chunked body size median in production is unknown (and usually varies across projects).
 	// Maybe, we could add an option to the settings to pre-allocate the buffer in such cases,
 	// but this will affect only cold-start stages. Not sure, whether it's time-worthy
-	if len(b.bodyBuff) < b.contentLength {
+	if cap(b.bodyBuff) < b.contentLength && !b.exceedsMaxSize(b.contentLength) {
 		b.bodyBuff = make([]byte, 0, b.contentLength)
 	}
 
 	b.bodyBuff = b.bodyBuff[:0]
-
-	return b.bodyBuff, b.callback(func(data []byte) error {
+	err := b.callback(func(data []byte) error {
 		b.bodyBuff = append(b.bodyBuff, data...)
 		return nil
 	})
+
+	return b.bodyBuff, err
+}
+
+// Form parses the body as application/x-www-form-urlencoded, e.g. a response of OAuth
+// token endpoints. Unlike Full, the result is safe to be used after the next request
+func (b *Body) Form() (query.Query, error) {
+	data, err := b.Full()
+	if err != nil {
+		return nil, err
+	}
+
+	return query.Parse(string(data))
+}
+
+// Decode decodes the body into the v by the codec, registered for the Content-Type of
+// the response. The body is streamed into the codec, so it isn't buffered whole. If
+// there is no suitable codec, *codec.UnsupportedError is returned
+func (b *Body) Decode(v any) error {
+	c, err := b.codecs.Get(b.contentType)
+	if err != nil {
+		return err
+	}
+
+	return c.Decode(b, v)
 }
 
 // Read implements the io.Reader interface, so behaves respectively
 func (b *Body) Read(into []byte) (n int, err error) {
-	data, err := b.unreader.PendingOr(b.reader.Read)
+	data, err := b.unreader.PendingOr(b.next)
 	if err != nil {
 		return 0, err
 	}
@@ -87,18 +170,51 @@ func (b *Body) Callback(onBody onBodyCallback) error {
 	return b.callback(onBody)
 }
 
+// Close discards the rest of the body. If the response was obtained from the pool, the
+// session is returned back to it, so the response must not be used after that
+func (b *Body) Close() error {
+	var err error
+	if !b.empty {
+		err = b.Reset()
+	}
+
+	if onClose := b.onClose; onClose != nil {
+		b.onClose = nil
+		onClose(err)
+	}
+
+	return err
+}
+
+// OnClose is a system method, that sets a callback, called once the body is closed
+func (b *Body) OnClose(cb func(error)) {
+	b.onClose = cb
+}
+
+// OnDone is a system method, that sets a callback, called once the body is received
+// completely, or reading it fails
+func (b *Body) OnDone(cb func(error)) {
+	b.onDone = cb
+}
+
 // Reset resets the body.
 //
 // NOTE: this is a system method, that SHOULD NOT be called by user manually. However,
 // this won't affect anything anyhow, except impossibility to restore the body data
 func (b *Body) Reset() error {
+	if b.empty {
+		return nil
+	}
+
 	for {
 		_, err := b.reader.Read()
 		switch err {
 		case nil:
 		case io.EOF:
+			b.done(nil)
 			return nil
 		default:
+			b.done(err)
 			return err
 		}
 	}
@@ -106,7 +222,7 @@ func (b *Body) Reset() error {
 
 func (b *Body) callback(onBody onBodyCallback) error {
 	for {
-		piece, err := b.reader.Read()
+		piece, err := b.next()
 		switch err {
 		case nil:
 		case io.EOF:
@@ -120,3 +236,164 @@ func (b *Body) callback(onBody onBodyCallback) error {
 		}
 	}
 }
+
+// next returns the next piece of the body, decoded if needed
+func (b *Body) next() (data []byte, err error) {
+	switch {
+	case b.empty:
+		return nil, io.EOF
+	case b.raw || !b.isEncoded():
+		data, err = b.reader.Read()
+	default:
+		data, err = b.decode()
+	}
+
+	if err != nil {
+		b.done(err)
+	}
+
+	return data, err
+}
+
+func (b *Body) done(err error) {
+	if onDone := b.onDone; onDone != nil {
+		b.onDone = nil
+		if err == io.EOF {
+			err = nil
+		}
+
+		onDone(err)
+	}
+}
+
+// decode returns the next piece of the decoded body. Decoders are chained in the
+// reversed order, as the Content-Encoding lists codings in order they were applied in
+func (b *Body) decode() ([]byte, error) {
+	if b.decoded {
+		return nil, io.EOF
+	}
+
+	if b.decoder == nil {
+		if err := b.initDecoders(); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.decodeBuff == nil {
+		b.decodeBuff = make([]byte, decodeBuffSize)
+	}
+
+	for {
+		n, err := b.decoder.Read(b.decodeBuff)
+		if n > 0 {
+			b.decodedSize += int64(n)
+			if limit := b.settings.MaxDecodedSize; limit > 0 && b.decodedSize > limit {
+				return nil, status.ErrDecodedBodyTooLarge
+			}
+
+			return b.decodeBuff[:n], nil
+		}
+
+		switch err {
+		case nil:
+		case io.EOF:
+			b.decoded = true
+			b.closeDecoders()
+			return nil, b.drain()
+		default:
+			return nil, err
+		}
+	}
+}
+
+// drain reads the rest of the raw body after the decoded stream has ended. Decoders
+// may stop before the raw body does, however the body must be consumed completely,
+// e.g. so the trailers are parsed. Any data left is an error
+func (b *Body) drain() error {
+	if len(b.source.pending) > 0 {
+		return status.ErrTrailingBodyData
+	}
+
+	for {
+		data, err := b.reader.Read()
+		switch {
+		case err != nil:
+			return err
+		case len(data) > 0:
+			return status.ErrTrailingBodyData
+		}
+	}
+}
+
+func (b *Body) initDecoders() error {
+	var src io.Reader = &b.source
+
+	for i := len(b.encoding.Content) - 1; i >= 0; i-- {
+		token := b.encoding.Content[i]
+		if isIdentity(token) {
+			continue
+		}
+
+		decoder, err := b.codings.NewLimitedReader(token, src, b.settings.MaxDecodedSize)
+		if err != nil {
+			b.closeDecoders()
+			return err
+		}
+
+		b.decoders = append(b.decoders, decoder)
+		src = decoder
+	}
+
+	b.decoder = src
+
+	return nil
+}
+
+func (b *Body) closeDecoders() {
+	for _, decoder := range b.decoders {
+		_ = decoder.Close()
+	}
+
+	b.decoders = b.decoders[:0]
+	b.decoder = nil
+}
+
+// exceedsMaxSize reports, whether the body of such a length won't be read anyway. So
+// memory for it mustn't be allocated in advance
+func (b *Body) exceedsMaxSize(length int) bool {
+	return b.settings.MaxSize > 0 && int64(length) > b.settings.MaxSize
+}
+
+func (b *Body) isEncoded() bool {
+	for _, token := range b.encoding.Content {
+		if !isIdentity(token) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isIdentity(token coding.Token) bool {
+	return strcomp.EqualFold(token, "identity")
+}
+
+// pieceReader adapts BodyReader to the io.Reader, so the raw body can be passed
+// into the decoders
+type pieceReader struct {
+	reader  BodyReader
+	pending []byte
+}
+
+func (p *pieceReader) Read(b []byte) (n int, err error) {
+	if len(p.pending) == 0 {
+		if p.pending, err = p.reader.Read(); err != nil {
+			return 0, err
+		}
+	}
+
+	n = copy(b, p.pending)
+	p.pending = p.pending[n:]
+
+	return n, nil
+}
@@ -0,0 +1,252 @@
+package http
+
+import (
+	"github.com/indigo-web/client/http/codec"
+	"github.com/indigo-web/client/http/coding"
+	"github.com/indigo-web/client/http/status"
+	"github.com/indigo-web/client/settings"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+type sliceReader struct {
+	pieces [][]byte
+}
+
+func newSliceReader(data []byte, pieceSize int) *sliceReader {
+	var pieces [][]byte
+
+	for len(data) > pieceSize {
+		pieces = append(pieces, data[:pieceSize])
+		data = data[pieceSize:]
+	}
+
+	return &sliceReader{
+		pieces: append(pieces, data),
+	}
+}
+
+func (s *sliceReader) Init(*Response) {}
+
+func (s *sliceReader) Read() ([]byte, error) {
+	if len(s.pieces) == 0 {
+		return nil, io.EOF
+	}
+
+	piece := s.pieces[0]
+	s.pieces = s.pieces[1:]
+
+	return piece, nil
+}
+
+func newEncodedResponse(data []byte, codings coding.Manager, tokens ...string) *Response {
+	resp := NewResponse(newSliceReader(data, 7), codings, codec.NewDefaultManager(), settings.Body{})
+	resp.Encoding.Content = tokens
+	resp.ContentLength = len(data)
+	resp.Body.Init(resp)
+
+	return resp
+}
+
+// reverseCoding is a slice-based coding, which just reverses the data
+type reverseCoding struct{}
+
+func (reverseCoding) Encode(input []byte) ([]byte, error) {
+	return reverse(input), nil
+}
+
+func (reverseCoding) Decode(input []byte) ([]byte, error) {
+	return reverse(input), nil
+}
+
+// countingCoding is reverseCoding, which counts how many times it decoded anything
+type countingCoding struct {
+	reverseCoding
+	decoded *int
+}
+
+func (c countingCoding) Decode(input []byte) ([]byte, error) {
+	*c.decoded++
+	return c.reverseCoding.Decode(input)
+}
+
+func reverse(input []byte) []byte {
+	output := make([]byte, len(input))
+	for i, char := range input {
+		output[len(input)-1-i] = char
+	}
+
+	return output
+}
+
+func TestBody(t *testing.T) {
+	const sample = "Hello, world! Lorem ipsum dolor sit amet"
+	codings := coding.NewDefaultManager()
+
+	t.Run("plain", func(t *testing.T) {
+		resp := newEncodedResponse([]byte(sample), codings)
+		body, err := resp.Body.Full()
+		require.NoError(t, err)
+		require.Equal(t, sample, string(body))
+	})
+
+	t.Run("form", func(t *testing.T) {
+		resp := newEncodedResponse([]byte("access_token=abc%2F1&token_type=bearer&scope=a+b"), codings)
+		form, err := resp.Body.Form()
+		require.NoError(t, err)
+		require.Equal(t, "abc/1", form.Get("access_token"))
+		require.Equal(t, "bearer", form.Get("token_type"))
+		require.Equal(t, "a b", form.Get("scope"))
+	})
+
+	t.Run("decode", func(t *testing.T) {
+		newJSONResponse := func(contentType string) *Response {
+			resp := NewResponse(newSliceReader([]byte(`{"name":"indigo","tags":["a","b"]}`), 5), codings, codec.NewDefaultManager(), settings.Body{})
+			resp.ContentType = contentType
+			resp.Body.Init(resp)
+
+			return resp
+		}
+
+		type payload struct {
+			Name string   `json:"name"`
+			Tags []string `json:"tags"`
+		}
+
+		for _, contentType := range []string{"application/json; charset=utf-8", "application/problem+json"} {
+			var v payload
+			require.NoError(t, newJSONResponse(contentType).Body.Decode(&v))
+			require.Equal(t, payload{Name: "indigo", Tags: []string{"a", "b"}}, v)
+		}
+
+		var unsupportedErr *codec.UnsupportedError
+		require.ErrorAs(t, newJSONResponse("text/html").Body.Decode(new(payload)), &unsupportedErr)
+		require.Equal(t, "text/html", unsupportedErr.ContentType)
+		require.ErrorIs(t, newJSONResponse("").Body.Decode(new(payload)), codec.ErrNoContentType)
+	})
+
+	t.Run("gzip", func(t *testing.T) {
+		encoded, err := codings.Encode("gzip", []byte(sample))
+		require.NoError(t, err)
+		resp := newEncodedResponse(encoded, codings, "gzip")
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, sample, string(body))
+	})
+
+	for _, token := range []string{"deflate", "zstd", "br"} {
+		t.Run(token, func(t *testing.T) {
+			encoded, err := codings.Encode(token, []byte(sample))
+			require.NoError(t, err)
+			resp := newEncodedResponse(encoded, codings, token)
+			body, err := resp.Body.Full()
+			require.NoError(t, err)
+			require.Equal(t, sample, string(body))
+		})
+	}
+
+	t.Run("malformed", func(t *testing.T) {
+		resp := newEncodedResponse([]byte(sample), codings, "zstd")
+		_, err := resp.Body.Full()
+		var decodeErr *coding.DecodeError
+		require.ErrorAs(t, err, &decodeErr)
+		require.Equal(t, "zstd", decodeErr.Token)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		resp := newEncodedResponse([]byte(sample), codings, "gzip", "compress")
+		_, err := resp.Body.Full()
+		var unsupportedErr *coding.UnsupportedError
+		require.ErrorAs(t, err, &unsupportedErr)
+		require.Equal(t, "compress", unsupportedErr.Token)
+		require.ErrorIs(t, err, coding.ErrUnknownToken)
+	})
+
+	t.Run("decoded size limit", func(t *testing.T) {
+		encoded, err := codings.Encode("gzip", make([]byte, 64*1024))
+		require.NoError(t, err)
+		resp := NewResponse(newSliceReader(encoded, 7), codings, codec.NewManager(), settings.Body{
+			MaxDecodedSize: 16 * 1024,
+		})
+		resp.Encoding.Content = []string{"gzip"}
+		resp.Body.Init(resp)
+		_, err = resp.Body.Full()
+		require.ErrorIs(t, err, status.ErrDecodedBodyTooLarge)
+	})
+
+	t.Run("decoded size limit of slice-based coding", func(t *testing.T) {
+		var decoded int
+		codings := coding.NewDefaultManager()
+		codings.AddCoding("reverse", countingCoding{decoded: &decoded})
+		encoded, err := codings.Encode("reverse", make([]byte, 64*1024))
+		require.NoError(t, err)
+		encoded, err = codings.Encode("gzip", encoded)
+		require.NoError(t, err)
+		resp := NewResponse(newSliceReader(encoded, 7), codings, codec.NewManager(), settings.Body{
+			MaxDecodedSize: 16 * 1024,
+		})
+		resp.Encoding.Content = []string{"reverse", "gzip"}
+		resp.Body.Init(resp)
+		_, err = resp.Body.Full()
+		require.ErrorIs(t, err, status.ErrDecodedBodyTooLarge)
+		require.Zero(t, decoded)
+	})
+
+	t.Run("trailing data", func(t *testing.T) {
+		encoded, err := codings.Encode("deflate", []byte(sample))
+		require.NoError(t, err)
+		resp := newEncodedResponse(append(encoded, "garbage"...), codings, "deflate")
+		_, err = resp.Body.Full()
+		require.ErrorIs(t, err, status.ErrTrailingBodyData)
+	})
+
+	t.Run("drained after decoding", func(t *testing.T) {
+		encoded, err := codings.Encode("deflate", []byte(sample))
+		require.NoError(t, err)
+		// the empty piece emulates the end of the chunked body, which the decoder
+		// doesn't need to read, however it must be consumed anyway
+		reader := newSliceReader(encoded, 7)
+		reader.pieces = append(reader.pieces, nil)
+		resp := NewResponse(reader, codings, codec.NewManager(), settings.Body{})
+		resp.Encoding.Content = []string{"deflate"}
+		resp.Body.Init(resp)
+		body, err := resp.Body.Full()
+		require.NoError(t, err)
+		require.Equal(t, sample, string(body))
+		require.Empty(t, reader.pieces)
+	})
+
+	t.Run("chain", func(t *testing.T) {
+		encoded, err := codings.Encode("deflate", []byte(sample))
+		require.NoError(t, err)
+		encoded, err = codings.Encode("gzip", encoded)
+		require.NoError(t, err)
+		resp := newEncodedResponse(encoded, codings, "deflate", "identity", "x-gzip")
+		body, err := resp.Body.Full()
+		require.NoError(t, err)
+		require.Equal(t, sample, string(body))
+	})
+
+	t.Run("slice-based coding", func(t *testing.T) {
+		codings := coding.NewDefaultManager()
+		codings.AddCoding("reverse", reverseCoding{})
+		encoded, err := codings.Encode("gzip", []byte(sample))
+		require.NoError(t, err)
+		encoded, err = codings.Encode("reverse", encoded)
+		require.NoError(t, err)
+		resp := newEncodedResponse(encoded, codings, "gzip", "reverse")
+		body, err := resp.Body.Full()
+		require.NoError(t, err)
+		require.Equal(t, sample, string(body))
+	})
+
+	t.Run("raw", func(t *testing.T) {
+		encoded, err := codings.Encode("gzip", []byte(sample))
+		require.NoError(t, err)
+		resp := newEncodedResponse(encoded, codings, "gzip")
+		body, err := resp.Body.Raw().Full()
+		require.NoError(t, err)
+		require.Equal(t, encoded, body)
+	})
+}
@@ -0,0 +1,82 @@
+package codec
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNoContentType = errors.New("content type is not specified")
+)
+
+// Codec serializes values into bodies of its content type and back
+type Codec interface {
+	Encode(dst io.Writer, v any) error
+	Decode(src io.Reader, v any) error
+}
+
+type Manager struct {
+	codecs map[string]Codec
+}
+
+func NewManager() Manager {
+	return Manager{
+		codecs: make(map[string]Codec),
+	}
+}
+
+// NewDefaultManager returns a manager with built-in JSON codec already registered
+func NewDefaultManager() Manager {
+	m := NewManager()
+	m.Add("application/json", JSON{})
+
+	return m
+}
+
+// Add registers the codec for the media type, e.g. application/xml. Structured syntax
+// suffixes are resolved automatically, so application/problem+json is decoded by the
+// codec, registered for application/json, unless it has its own one
+func (m Manager) Add(mediaType string, codec Codec) {
+	m.codecs[strings.ToLower(mediaType)] = codec
+}
+
+// Get returns the codec for the value of the Content-Type header. Parameters, like
+// charset, are ignored. *UnsupportedError is returned, if there is no such codec
+func (m Manager) Get(contentType string) (Codec, error) {
+	mediaType := MediaType(contentType)
+	if len(mediaType) == 0 {
+		return nil, ErrNoContentType
+	}
+
+	if codec, found := m.codecs[mediaType]; found {
+		return codec, nil
+	}
+
+	if plus := strings.LastIndexByte(mediaType, '+'); plus != -1 {
+		if codec, found := m.codecs["application/"+mediaType[plus+1:]]; found {
+			return codec, nil
+		}
+	}
+
+	return nil, &UnsupportedError{ContentType: contentType}
+}
+
+// MediaType returns the content type without parameters, lower-cased
+func MediaType(contentType string) string {
+	if semicolon := strings.IndexByte(contentType, ';'); semicolon != -1 {
+		contentType = contentType[:semicolon]
+	}
+
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+// UnsupportedError is returned, when there is no codec registered for the content type
+type UnsupportedError struct {
+	ContentType string
+}
+
+func (u *UnsupportedError) Error() string {
+	return "codec: no codec registered for content type " + strconv.Quote(u.ContentType)
+}
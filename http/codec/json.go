@@ -0,0 +1,17 @@
+package codec
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// JSON is the codec of application/json, based on encoding/json
+type JSON struct{}
+
+func (JSON) Encode(dst io.Writer, v any) error {
+	return json.NewEncoder(dst).Encode(v)
+}
+
+func (JSON) Decode(src io.Reader, v any) error {
+	return json.NewDecoder(src).Decode(v)
+}
@@ -0,0 +1,18 @@
+package codec
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// XML is the codec of application/xml, based on encoding/xml. It isn't registered by
+// default
+type XML struct{}
+
+func (XML) Encode(dst io.Writer, v any) error {
+	return xml.NewEncoder(dst).Encode(v)
+}
+
+func (XML) Decode(src io.Reader, v any) error {
+	return xml.NewDecoder(src).Decode(v)
+}
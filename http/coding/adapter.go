@@ -0,0 +1,107 @@
+package coding
+
+import (
+	"bytes"
+	"github.com/indigo-web/client/http/status"
+	"io"
+)
+
+// encoderAdapter makes a stream encoder out of slice-based one. All the written
+// data is buffered and gets encoded only when the writer is closed
+type encoderAdapter struct {
+	encoder Encoder
+}
+
+func (e encoderAdapter) NewWriter(dst io.Writer) (io.WriteCloser, error) {
+	return &bufferedEncoder{
+		encoder: e.encoder,
+		dst:     dst,
+	}, nil
+}
+
+type bufferedEncoder struct {
+	encoder Encoder
+	dst     io.Writer
+	buff    []byte
+}
+
+func (b *bufferedEncoder) Write(p []byte) (n int, err error) {
+	b.buff = append(b.buff, p...)
+	return len(p), nil
+}
+
+func (b *bufferedEncoder) Close() error {
+	output, err := b.encoder.Encode(b.buff)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.dst.Write(output)
+	return err
+}
+
+// decoderAdapter makes a stream decoder out of slice-based one. The whole source is
+// read and decoded during the first read. If the limit is set, neither the source nor
+// the decoded output may exceed it, as both are held in memory entirely
+type decoderAdapter struct {
+	decoder Decoder
+	limit   int64
+}
+
+func (d decoderAdapter) NewReader(src io.Reader) (io.ReadCloser, error) {
+	return &bufferedDecoder{
+		decoder: d.decoder,
+		src:     src,
+		limit:   d.limit,
+	}, nil
+}
+
+type bufferedDecoder struct {
+	decoder Decoder
+	src     io.Reader
+	limit   int64
+	output  *bytes.Reader
+}
+
+func (b *bufferedDecoder) Read(p []byte) (n int, err error) {
+	if b.output == nil {
+		input, err := b.readSource()
+		if err != nil {
+			return 0, err
+		}
+
+		output, err := b.decoder.Decode(input)
+		if err != nil {
+			return 0, err
+		}
+
+		if b.exceeds(len(output)) {
+			return 0, status.ErrDecodedBodyTooLarge
+		}
+
+		b.output = bytes.NewReader(output)
+	}
+
+	return b.output.Read(p)
+}
+
+func (b *bufferedDecoder) readSource() ([]byte, error) {
+	if b.limit <= 0 {
+		return io.ReadAll(b.src)
+	}
+
+	input, err := io.ReadAll(io.LimitReader(b.src, b.limit+1))
+	if err == nil && b.exceeds(len(input)) {
+		err = status.ErrDecodedBodyTooLarge
+	}
+
+	return input, err
+}
+
+func (b *bufferedDecoder) exceeds(size int) bool {
+	return b.limit > 0 && int64(size) > b.limit
+}
+
+func (b *bufferedDecoder) Close() error {
+	return nil
+}
@@ -0,0 +1,21 @@
+package coding
+
+import (
+	"github.com/andybalholm/brotli"
+	"io"
+)
+
+type brotliCoding struct{}
+
+// NewBrotli returns a coding for br token
+func NewBrotli() StreamCoding {
+	return brotliCoding{}
+}
+
+func (brotliCoding) NewWriter(dst io.Writer) (io.WriteCloser, error) {
+	return brotli.NewWriter(dst), nil
+}
+
+func (brotliCoding) NewReader(src io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(brotli.NewReader(src)), nil
+}
@@ -1,7 +1,12 @@
 package coding
 
 import (
+	"bytes"
 	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 var (
@@ -24,45 +29,201 @@ type Coding interface {
 	Decoder
 }
 
+// StreamEncoder wraps the destination into a writer, that encodes everything written
+// into it. The writer must be closed in order to flush all the pending data
+type StreamEncoder interface {
+	NewWriter(dst io.Writer) (io.WriteCloser, error)
+}
+
+// StreamDecoder wraps the source of encoded data into a reader of decoded data
+type StreamDecoder interface {
+	NewReader(src io.Reader) (io.ReadCloser, error)
+}
+
+type StreamCoding interface {
+	StreamEncoder
+	StreamDecoder
+}
+
 type Manager struct {
-	encoders map[Token]Encoder
-	decoders map[Token]Decoder
+	encoders map[Token]StreamEncoder
+	decoders map[Token]StreamDecoder
 }
 
 func NewManager() Manager {
 	return Manager{
-		encoders: make(map[Token]Encoder),
-		decoders: make(map[Token]Decoder),
+		encoders: make(map[Token]StreamEncoder),
+		decoders: make(map[Token]StreamDecoder),
 	}
 }
 
+// NewDefaultManager returns a manager with built-in codings (gzip, deflate, zstd
+// and br) already registered
+func NewDefaultManager() Manager {
+	m := NewManager()
+	m.AddStreamCoding("gzip", NewGZIP())
+	m.AddStreamCoding("deflate", NewDeflate())
+	m.AddStreamCoding("zstd", NewZSTD())
+	m.AddStreamCoding("br", NewBrotli())
+
+	return m
+}
+
+// AddCoding registers both encoder and decoder of the coding
+func (m Manager) AddCoding(token Token, coding Coding) {
+	m.AddEncoder(token, coding)
+	m.AddDecoder(token, coding)
+}
+
+// AddEncoder registers a slice-based encoder. As it cannot process the data piece by
+// piece, it's adapted to the stream interface by buffering the whole input
 func (m Manager) AddEncoder(token Token, encoder Encoder) {
-	addCoding(token, encoder, m.encoders)
+	m.AddStreamEncoder(token, encoderAdapter{encoder})
 }
 
+// AddDecoder registers a slice-based decoder. As it cannot process the data piece by
+// piece, it's adapted to the stream interface by buffering the whole input. See
+// NewLimitedReader on how the buffered data is limited
 func (m Manager) AddDecoder(token Token, decoder Decoder) {
+	m.AddStreamDecoder(token, decoderAdapter{decoder: decoder})
+}
+
+// AddStreamCoding registers both stream encoder and decoder of the coding
+func (m Manager) AddStreamCoding(token Token, coding StreamCoding) {
+	m.AddStreamEncoder(token, coding)
+	m.AddStreamDecoder(token, coding)
+}
+
+func (m Manager) AddStreamEncoder(token Token, encoder StreamEncoder) {
+	addCoding(token, encoder, m.encoders)
+}
+
+func (m Manager) AddStreamDecoder(token Token, decoder StreamDecoder) {
 	addCoding(token, decoder, m.decoders)
 }
 
-func (m Manager) Encode(token Token, input []byte) (output []byte, err error) {
-	encoder, found := m.encoders[token]
+// NewWriter returns a writer, that encodes the data using the coding and writes it
+// into the dst
+func (m Manager) NewWriter(token Token, dst io.Writer) (io.WriteCloser, error) {
+	encoder, found := m.encoders[strings.ToLower(token)]
 	if !found {
-		return nil, ErrUnknownToken
+		return nil, &UnsupportedError{Token: token}
 	}
 
-	return encoder.Encode(input)
+	return encoder.NewWriter(dst)
 }
 
-func (m Manager) Decode(token Token, input []byte) (output []byte, err error) {
-	decoder, found := m.decoders[token]
+// NewReader returns a reader, that decodes the data from src using the coding. Errors
+// of the decoder are returned as *DecodeError, and *UnsupportedError is returned if
+// there is no decoder for the token
+func (m Manager) NewReader(token Token, src io.Reader) (io.ReadCloser, error) {
+	return m.NewLimitedReader(token, src, 0)
+}
+
+// NewLimitedReader is the same as NewReader, however slice-based decoders fail with
+// status.ErrDecodedBodyTooLarge, if either their input or output exceeds the limit, as
+// they hold both in memory. Output of stream decoders must be limited by the caller.
+// Zero limit means no limit
+func (m Manager) NewLimitedReader(token Token, src io.Reader, limit int64) (io.ReadCloser, error) {
+	decoder, found := m.decoders[strings.ToLower(token)]
 	if !found {
-		return nil, ErrUnknownToken
+		return nil, &UnsupportedError{Token: token}
+	}
+
+	if adapter, ok := decoder.(decoderAdapter); ok {
+		adapter.limit = limit
+		decoder = adapter
+	}
+
+	source := &sourceReader{src: src}
+	reader, err := decoder.NewReader(source)
+	if err != nil {
+		if err == source.err {
+			return nil, err
+		}
+
+		return nil, &DecodeError{Token: token, Err: err}
+	}
+
+	return decodingReader{
+		token:   token,
+		src:     source,
+		decoder: reader,
+	}, nil
+}
+
+// Accept returns a value for the Accept-Encoding header, listing all the registered
+// decoders with q-values. Preferred codings go first in the passed order, then built-in
+// ones, and the rest are sorted alphabetically. Preferred codings, that aren't
+// registered, are ignored
+func (m Manager) Accept(preferred ...Token) string {
+	tokens := make([]Token, 0, len(m.decoders))
+	for token := range m.decoders {
+		if !isAlias(token) {
+			tokens = append(tokens, token)
+		}
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		iPriority, jPriority := priority(tokens[i], preferred), priority(tokens[j], preferred)
+		if iPriority != jPriority {
+			return iPriority < jPriority
+		}
+
+		return tokens[i] < tokens[j]
+	})
+
+	var accept strings.Builder
+
+	for i, token := range tokens {
+		if i > 0 {
+			accept.WriteString(", ")
+		}
+
+		accept.WriteString(token)
+		if q := qValue(i); len(q) > 0 {
+			accept.WriteString(";q=")
+			accept.WriteString(q)
+		}
 	}
 
-	return decoder.Decode(input)
+	return accept.String()
+}
+
+func (m Manager) Encode(token Token, input []byte) (output []byte, err error) {
+	var buff bytes.Buffer
+	writer, err := m.NewWriter(token, &buff)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = writer.Write(input); err != nil {
+		return nil, err
+	}
+
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buff.Bytes(), nil
+}
+
+func (m Manager) Decode(token Token, input []byte) (output []byte, err error) {
+	reader, err := m.NewReader(token, bytes.NewReader(input))
+	if err != nil {
+		return nil, err
+	}
+
+	output, err = io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return output, reader.Close()
 }
 
 func addCoding[V any](token Token, value V, into map[Token]V) {
+	token = strings.ToLower(token)
 	into[token] = value
 
 	// this exists in backward-capability purposes. Some old clients may use x-gzip or
@@ -75,3 +236,40 @@ func addCoding[V any](token Token, value V, into map[Token]V) {
 		into["x-compress"] = value
 	}
 }
+
+// builtins is the order, in which built-in codings are preferred
+var builtins = []Token{"zstd", "br", "gzip", "deflate"}
+
+func priority(token Token, preferred []Token) int {
+	for i, pref := range preferred {
+		if strings.EqualFold(token, pref) {
+			return i
+		}
+	}
+
+	for i, builtin := range builtins {
+		if token == builtin {
+			return len(preferred) + i
+		}
+	}
+
+	return len(preferred) + len(builtins)
+}
+
+// qValue returns a q-value for the coding at the position. The first one gets the
+// default 1, so it's omitted, and each next one is by 0.1 less. Nevertheless, the
+// q-value never goes below 0.1, as 0 would mean "not acceptable"
+func qValue(position int) string {
+	switch {
+	case position == 0:
+		return ""
+	case position >= 9:
+		return "0.1"
+	default:
+		return "0." + strconv.Itoa(10-position)
+	}
+}
+
+func isAlias(token Token) bool {
+	return token == "x-gzip" || token == "x-compress"
+}
@@ -0,0 +1,45 @@
+package coding
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/zlib"
+	"io"
+)
+
+type deflateCoding struct{}
+
+// NewDeflate returns a coding for deflate token. As the token actually means zlib
+// format (RFC 1950), it's produced when encoding. However, some servers send a raw
+// deflate stream (RFC 1951) instead, so it's also accepted when decoding
+func NewDeflate() StreamCoding {
+	return deflateCoding{}
+}
+
+func (deflateCoding) NewWriter(dst io.Writer) (io.WriteCloser, error) {
+	return zlib.NewWriter(dst), nil
+}
+
+func (deflateCoding) NewReader(src io.Reader) (io.ReadCloser, error) {
+	reader := bufio.NewReader(src)
+	header, err := reader.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if !isZlibHeader(header) {
+		return flate.NewReader(reader), nil
+	}
+
+	return zlib.NewReader(reader)
+}
+
+// isZlibHeader reports, whether the data starts with a valid zlib header: the
+// compression method must be deflate, and the header checksum must match
+func isZlibHeader(header []byte) bool {
+	if len(header) < 2 {
+		return false
+	}
+
+	return header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0
+}
@@ -0,0 +1,74 @@
+package coding
+
+import (
+	"io"
+)
+
+// UnsupportedError is returned, when there is no coding registered for the token.
+// It matches the ErrUnknownToken, when checked with errors.Is
+type UnsupportedError struct {
+	Token Token
+}
+
+func (u *UnsupportedError) Error() string {
+	return "coding " + u.Token + ": not supported"
+}
+
+func (u *UnsupportedError) Is(target error) bool {
+	return target == ErrUnknownToken
+}
+
+// DecodeError is returned, when the data can't be decoded by the coding. Errors,
+// occurred while reading the encoded data itself, aren't wrapped
+type DecodeError struct {
+	Token Token
+	Err   error
+}
+
+func (d *DecodeError) Error() string {
+	return "coding " + d.Token + ": " + d.Err.Error()
+}
+
+func (d *DecodeError) Unwrap() error {
+	return d.Err
+}
+
+// sourceReader remembers the last error returned by the source, so it can be
+// distinguished from errors, produced by the decoder
+type sourceReader struct {
+	src io.Reader
+	err error
+}
+
+func (s *sourceReader) Read(p []byte) (n int, err error) {
+	n, err = s.src.Read(p)
+	s.err = err
+	return n, err
+}
+
+// decodingReader wraps errors of the decoder into DecodeError
+type decodingReader struct {
+	token   Token
+	src     *sourceReader
+	decoder io.ReadCloser
+}
+
+func (d decodingReader) Read(p []byte) (n int, err error) {
+	n, err = d.decoder.Read(p)
+	return n, d.wrap(err)
+}
+
+func (d decodingReader) Close() error {
+	return d.wrap(d.decoder.Close())
+}
+
+func (d decodingReader) wrap(err error) error {
+	if err == nil || err == io.EOF || err == d.src.err {
+		return err
+	}
+
+	return &DecodeError{
+		Token: d.token,
+		Err:   err,
+	}
+}
@@ -0,0 +1,21 @@
+package coding
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+type gzipCoding struct{}
+
+// NewGZIP returns a coding for gzip (and x-gzip) token
+func NewGZIP() StreamCoding {
+	return gzipCoding{}
+}
+
+func (gzipCoding) NewWriter(dst io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(dst), nil
+}
+
+func (gzipCoding) NewReader(src io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(src)
+}
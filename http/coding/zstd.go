@@ -0,0 +1,39 @@
+package coding
+
+import (
+	"github.com/klauspost/compress/zstd"
+	"io"
+)
+
+type zstdCoding struct{}
+
+// NewZSTD returns a coding for zstd token
+func NewZSTD() StreamCoding {
+	return zstdCoding{}
+}
+
+func (zstdCoding) NewWriter(dst io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(dst, zstd.WithEncoderConcurrency(1))
+}
+
+func (zstdCoding) NewReader(src io.Reader) (io.ReadCloser, error) {
+	// decoding concurrently makes sense only for huge bodies, whereas goroutines
+	// are spawned for every single decoder
+	decoder, err := zstd.NewReader(src, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+
+	return zstdReader{decoder}, nil
+}
+
+// zstdReader exists because zstd.Decoder.Close doesn't return an error, so doesn't
+// implement io.Closer
+type zstdReader struct {
+	*zstd.Decoder
+}
+
+func (z zstdReader) Close() error {
+	z.Decoder.Close()
+	return nil
+}
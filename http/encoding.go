@@ -5,6 +5,9 @@ type Encoding struct {
 	Transfer []string
 	// Content represents Content-Encoding header value, split by comma
 	Content []string
+	// Trailer represents Trailer header value, split by comma. These are the only
+	// trailer fields, that are exposed after the chunked body. Others are dropped
+	Trailer []string
 	// Chunked doesn't belong to any of encodings, as it is still must be processed individually
 	Chunked, HasTrailer bool
 }
@@ -12,6 +15,7 @@ type Encoding struct {
 func (e Encoding) Clear() Encoding {
 	e.Transfer = e.Transfer[:0]
 	e.Content = e.Content[:0]
+	e.Trailer = e.Trailer[:0]
 	e.Chunked = false
 	e.HasTrailer = false
 	return e
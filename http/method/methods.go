@@ -14,3 +14,14 @@ const (
 	TRACE   Method = "TRACE"
 	PATCH   Method = "PATCH"
 )
+
+// IsIdempotent reports, whether multiple identical requests with the method have the
+// same effect as a single one (RFC 9110, 9.2.2). Such requests are safe to be retried
+func IsIdempotent(m Method) bool {
+	switch m {
+	case GET, HEAD, PUT, DELETE, OPTIONS, TRACE:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,174 @@
+package multipart
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/indigo-web/client/internal/length"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Form is a multipart/form-data body. Parts aren't buffered, instead they're read from
+// their sources right when the body is being sent
+type Form struct {
+	boundary string
+	parts    []part
+}
+
+type part struct {
+	header string
+	src    io.Reader
+}
+
+// New returns an empty form with a random boundary
+func New() *Form {
+	return &Form{
+		boundary: randomBoundary(),
+	}
+}
+
+// WithBoundary sets a custom boundary, e.g. to get reproducible bodies
+func (f *Form) WithBoundary(boundary string) *Form {
+	f.boundary = boundary
+	return f
+}
+
+// WithField adds a text field
+func (f *Form) WithField(name, value string) *Form {
+	return f.WithPart(name, "", strings.NewReader(value))
+}
+
+// WithBytes adds an in-memory part with the content type
+func (f *Form) WithBytes(name, contentType string, data []byte) *Form {
+	return f.WithPart(name, contentType, bytes.NewReader(data))
+}
+
+// WithPart adds a part, read from the src. Empty content type means it's omitted
+func (f *Form) WithPart(name, contentType string, src io.Reader) *Form {
+	return f.add(disposition(name, ""), contentType, src)
+}
+
+// WithFile adds a file part. Its filename is the base of the file's name, and the
+// content type is guessed by the extension, falling back to application/octet-stream
+func (f *Form) WithFile(name string, file *os.File) *Form {
+	filename := filepath.Base(file.Name())
+	return f.WithReader(name, filename, contentTypeOf(filename), file)
+}
+
+// WithReader adds a file part, read from the src
+func (f *Form) WithReader(name, filename, contentType string, src io.Reader) *Form {
+	return f.add(disposition(name, filename), contentType, src)
+}
+
+// Boundary returns the boundary of the form
+func (f *Form) Boundary() string {
+	return f.boundary
+}
+
+// ContentType returns a value for the Content-Type header of the request
+func (f *Form) ContentType() string {
+	return "multipart/form-data; boundary=" + f.boundary
+}
+
+// Reader returns the reader of the whole encoded form. If sizes of all the parts are
+// known, the reader has Len() int method, so the form is sent with Content-Length.
+// As parts are consumed from their sources, the reader may be obtained only once
+func (f *Form) Reader() io.Reader {
+	readers := make([]io.Reader, 0, len(f.parts)*2+1)
+	size := 0
+
+	for i, p := range f.parts {
+		delimiter := "\r\n--" + f.boundary + "\r\n"
+		if i == 0 {
+			delimiter = delimiter[2:]
+		}
+
+		header := delimiter + p.header
+		readers = append(readers, strings.NewReader(header), p.src)
+
+		if partSize := length.Of(p.src); partSize >= 0 && size >= 0 {
+			size += len(header) + int(partSize)
+		} else {
+			size = -1
+		}
+	}
+
+	closing := "--" + f.boundary + "--\r\n"
+	if len(f.parts) > 0 {
+		closing = "\r\n" + closing
+	}
+
+	readers = append(readers, strings.NewReader(closing))
+	reader := io.MultiReader(readers...)
+	if size < 0 {
+		return reader
+	}
+
+	return &sizedReader{
+		reader: reader,
+		left:   size + len(closing),
+	}
+}
+
+func (f *Form) add(disposition, contentType string, src io.Reader) *Form {
+	header := "Content-Disposition: " + disposition + "\r\n"
+	if len(contentType) > 0 {
+		header += "Content-Type: " + contentType + "\r\n"
+	}
+
+	f.parts = append(f.parts, part{
+		header: header + "\r\n",
+		src:    src,
+	})
+
+	return f
+}
+
+func disposition(name, filename string) string {
+	value := `form-data; name="` + quoteEscaper.Replace(name) + `"`
+	if len(filename) > 0 {
+		value += `; filename="` + quoteEscaper.Replace(filename) + `"`
+	}
+
+	return value
+}
+
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\r", "%0D", "\n", "%0A")
+
+func contentTypeOf(filename string) string {
+	if contentType := mime.TypeByExtension(filepath.Ext(filename)); len(contentType) > 0 {
+		return contentType
+	}
+
+	return "application/octet-stream"
+}
+
+func randomBoundary() string {
+	var buff [30]byte
+	if _, err := io.ReadFull(rand.Reader, buff[:]); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(buff[:])
+}
+
+// sizedReader is a reader of the known size. The size is exposed via Len() int, so
+// the renderer is able to set Content-Length
+type sizedReader struct {
+	reader io.Reader
+	left   int
+}
+
+func (s *sizedReader) Read(p []byte) (n int, err error) {
+	n, err = s.reader.Read(p)
+	s.left -= n
+	return n, err
+}
+
+func (s *sizedReader) Len() int {
+	return s.left
+}
@@ -0,0 +1,100 @@
+package multipart
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"io"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestForm(t *testing.T) {
+	parse := func(t *testing.T, form *Form, body io.Reader) *multipart.Reader {
+		mediaType, params, err := mime.ParseMediaType(form.ContentType())
+		require.NoError(t, err)
+		require.Equal(t, "multipart/form-data", mediaType)
+		require.Equal(t, form.Boundary(), params["boundary"])
+
+		return multipart.NewReader(body, params["boundary"])
+	}
+
+	t.Run("fields and files", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "data.txt")
+		require.NoError(t, os.WriteFile(filename, []byte("file content"), 0644))
+		file, err := os.Open(filename)
+		require.NoError(t, err)
+		defer file.Close()
+
+		form := New().
+			WithField("name", `with "quotes"`).
+			WithBytes("raw", "application/json", []byte(`{"a":1}`)).
+			WithFile("upload", file)
+
+		reader := form.Reader()
+		sized, ok := reader.(interface{ Len() int })
+		require.True(t, ok)
+		size := sized.Len()
+		encoded, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.Len(t, encoded, size)
+
+		mr := parse(t, form, bytes.NewReader(encoded))
+
+		part, err := mr.NextPart()
+		require.NoError(t, err)
+		require.Equal(t, "name", part.FormName())
+		require.Empty(t, part.Header.Get("Content-Type"))
+		data, err := io.ReadAll(part)
+		require.NoError(t, err)
+		require.Equal(t, `with "quotes"`, string(data))
+
+		part, err = mr.NextPart()
+		require.NoError(t, err)
+		require.Equal(t, "raw", part.FormName())
+		require.Equal(t, "application/json", part.Header.Get("Content-Type"))
+		data, err = io.ReadAll(part)
+		require.NoError(t, err)
+		require.Equal(t, `{"a":1}`, string(data))
+
+		part, err = mr.NextPart()
+		require.NoError(t, err)
+		require.Equal(t, "upload", part.FormName())
+		require.Equal(t, "data.txt", part.FileName())
+		require.True(t, strings.HasPrefix(part.Header.Get("Content-Type"), "text/plain"))
+		data, err = io.ReadAll(part)
+		require.NoError(t, err)
+		require.Equal(t, "file content", string(data))
+
+		_, err = mr.NextPart()
+		require.Equal(t, io.EOF, err)
+	})
+
+	t.Run("reader of unknown size", func(t *testing.T) {
+		src := io.LimitReader(strings.NewReader("streamed"), 100)
+		form := New().WithReader("stream", "stream.bin", "application/octet-stream", src)
+		reader := form.Reader()
+		_, sized := reader.(interface{ Len() int })
+		require.False(t, sized)
+
+		part, err := parse(t, form, reader).NextPart()
+		require.NoError(t, err)
+		require.Equal(t, "stream.bin", part.FileName())
+		data, err := io.ReadAll(part)
+		require.NoError(t, err)
+		require.Equal(t, "streamed", string(data))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		form := New()
+		_, err := parse(t, form, form.Reader()).NextPart()
+		require.Equal(t, io.EOF, err)
+	})
+
+	t.Run("random boundary", func(t *testing.T) {
+		require.NotEqual(t, New().Boundary(), New().Boundary())
+	})
+}
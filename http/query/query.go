@@ -1,3 +1,179 @@
 package query
 
+import (
+	"github.com/indigo-web/client/http/status"
+	"sort"
+	"strings"
+)
+
 type Query map[string][]string
+
+// Add appends the value to the key
+func (q Query) Add(key, value string) {
+	q[key] = append(q[key], value)
+}
+
+// Get returns the first value of the key, or an empty string, if there is no such
+func (q Query) Get(key string) string {
+	if values := q[key]; len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+// Encode returns the query, percent-encoded and ready to be appended to the path
+// (without the leading question mark). Keys are sorted, so the same query always
+// results in the same string, which is friendly to caches
+func (q Query) Encode() string {
+	return string(q.AppendEncoded(nil))
+}
+
+// AppendEncoded does the same as Encode does, but appends the result to the buff
+func (q Query) AppendEncoded(buff []byte) []byte {
+	keys := make([]string, 0, len(q))
+	for key := range q {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	offset := len(buff)
+
+	for _, key := range keys {
+		for _, value := range q[key] {
+			if len(buff) > offset {
+				buff = append(buff, '&')
+			}
+
+			buff = AppendEscaped(buff, key)
+			buff = append(buff, '=')
+			buff = AppendEscaped(buff, value)
+		}
+	}
+
+	return buff
+}
+
+// Parse parses the raw query string (without the leading question mark). Both %20
+// and + are decoded into a space
+func Parse(raw string) (Query, error) {
+	q := make(Query)
+
+	for len(raw) > 0 {
+		var pair string
+		if amp := strings.IndexByte(raw, '&'); amp == -1 {
+			pair, raw = raw, ""
+		} else {
+			pair, raw = raw[:amp], raw[amp+1:]
+		}
+
+		if len(pair) == 0 {
+			continue
+		}
+
+		rawKey, rawValue, _ := strings.Cut(pair, "=")
+		key, err := Unescape(rawKey)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := Unescape(rawValue)
+		if err != nil {
+			return nil, err
+		}
+
+		q.Add(key, value)
+	}
+
+	return q, nil
+}
+
+// FromPath parses the query of the path. It also may be used for absolute URLs, e.g.
+// one from the Location header. Fragment, if presented, is ignored
+func FromPath(path string) (Query, error) {
+	if hash := strings.IndexByte(path, '#'); hash != -1 {
+		path = path[:hash]
+	}
+
+	question := strings.IndexByte(path, '?')
+	if question == -1 {
+		return make(Query), nil
+	}
+
+	return Parse(path[question+1:])
+}
+
+// Escape percent-encodes everything except unreserved characters (RFC 3986, 2.3)
+func Escape(str string) string {
+	return string(AppendEscaped(nil, str))
+}
+
+// AppendEscaped does the same as Escape does, but appends the result to the buff
+func AppendEscaped(buff []byte, str string) []byte {
+	const hex = "0123456789ABCDEF"
+
+	for i := 0; i < len(str); i++ {
+		if char := str[i]; isUnreserved(char) {
+			buff = append(buff, char)
+		} else {
+			buff = append(buff, '%', hex[char>>4], hex[char&0xf])
+		}
+	}
+
+	return buff
+}
+
+// Unescape decodes percent-encoded characters, and pluses into spaces
+func Unescape(str string) (string, error) {
+	if strings.IndexByte(str, '%') == -1 && strings.IndexByte(str, '+') == -1 {
+		return str, nil
+	}
+
+	buff := make([]byte, 0, len(str))
+
+	for i := 0; i < len(str); i++ {
+		switch char := str[i]; char {
+		case '+':
+			buff = append(buff, ' ')
+		case '%':
+			if i+2 >= len(str) {
+				return "", status.ErrBadQuery
+			}
+
+			high, low := unhex(str[i+1]), unhex(str[i+2])
+			if high > 0xf || low > 0xf {
+				return "", status.ErrBadQuery
+			}
+
+			buff = append(buff, high<<4|low)
+			i += 2
+		default:
+			buff = append(buff, char)
+		}
+	}
+
+	return string(buff), nil
+}
+
+func isUnreserved(char byte) bool {
+	switch {
+	case char >= 'a' && char <= 'z', char >= 'A' && char <= 'Z', char >= '0' && char <= '9':
+		return true
+	}
+
+	return char == '-' || char == '.' || char == '_' || char == '~'
+}
+
+// unhex returns a value of the hex digit, or 0xff if the char isn't one
+func unhex(char byte) byte {
+	switch {
+	case char >= '0' && char <= '9':
+		return char - '0'
+	case char >= 'a' && char <= 'f':
+		return char - 'a' + 10
+	case char >= 'A' && char <= 'F':
+		return char - 'A' + 10
+	}
+
+	return 0xff
+}
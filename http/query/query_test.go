@@ -0,0 +1,57 @@
+package query
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	t.Run("encode", func(t *testing.T) {
+		q := Query{
+			"b":     {"2", "3"},
+			"a":     {"hello world"},
+			"ключ":  {"значение"},
+			"x&y=z": {"/?#+"},
+		}
+
+		require.Equal(t,
+			"a=hello%20world&b=2&b=3&x%26y%3Dz=%2F%3F%23%2B&%D0%BA%D0%BB%D1%8E%D1%87=%D0%B7%D0%BD%D0%B0%D1%87%D0%B5%D0%BD%D0%B8%D0%B5",
+			q.Encode(),
+		)
+	})
+
+	t.Run("parse", func(t *testing.T) {
+		q, err := Parse("a=hello+world&b=2&&b=3&x%26y%3Dz=%2F%3F%23%2B&empty")
+		require.NoError(t, err)
+		require.Equal(t, Query{
+			"a":     {"hello world"},
+			"b":     {"2", "3"},
+			"x&y=z": {"/?#+"},
+			"empty": {""},
+		}, q)
+	})
+
+	t.Run("roundtrip", func(t *testing.T) {
+		q := Query{"key": {"va lue", "100%"}, "ключ": {"значение"}}
+		parsed, err := Parse(q.Encode())
+		require.NoError(t, err)
+		require.Equal(t, q, parsed)
+	})
+
+	t.Run("bad escaping", func(t *testing.T) {
+		for _, raw := range []string{"a=%", "a=%2", "a=%zz"} {
+			_, err := Parse(raw)
+			require.Error(t, err, raw)
+		}
+	})
+
+	t.Run("from path", func(t *testing.T) {
+		q, err := FromPath("https://example.com/path?a=1&b=2#fragment")
+		require.NoError(t, err)
+		require.Equal(t, Query{"a": {"1"}, "b": {"2"}}, q)
+
+		q, err = FromPath("/path")
+		require.NoError(t, err)
+		require.Empty(t, q)
+	})
+}
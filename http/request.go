@@ -1,12 +1,33 @@
 package http
 
 import (
+	"bytes"
+	"context"
+	"github.com/indigo-web/client/http/codec"
+	"github.com/indigo-web/client/http/coding"
 	"github.com/indigo-web/client/http/headers"
 	"github.com/indigo-web/client/http/method"
+	"github.com/indigo-web/client/http/multipart"
 	"github.com/indigo-web/client/http/protocol"
+	"github.com/indigo-web/client/http/query"
 	"github.com/indigo-web/utils/uf"
 	"io"
 	"os"
+	"strings"
+)
+
+// AutoHeader is a header, that is set automatically, unless it's already set by the
+// user. Such headers may be disabled, e.g. for sending deliberately malformed requests
+type AutoHeader uint8
+
+const (
+	// AutoHost is the Host header, set to the address of the session
+	AutoHost AutoHeader = 1 << iota
+	// AutoContentLength is the Content-Length header, set if the body size is known
+	AutoContentLength
+	// AutoTransferEncoding is the Transfer-Encoding header, set if the body is streamed
+	// and its size is unknown. Disabling it disables chunked framing of the body as well
+	AutoTransferEncoding
 )
 
 type Request struct {
@@ -14,9 +35,25 @@ type Request struct {
 	Path    string
 	Proto   protocol.Protocol
 	Headers *headers.Headers
-	File    *os.File
-	Body    []byte
-	err     error
+	// File is a source of the body, that is sent with Content-Length of its size. Files,
+	// set directly, are owned by the caller, while ones opened by WithFile are closed
+	// by Close or WithClear
+	File *os.File
+	// Reader is a source of the body, that is streamed. If its size is unknown, chunked
+	// transfer encoding is used
+	Reader io.Reader
+	Body   []byte
+	// Compression is a coding, the body will be compressed with. If empty, the session's
+	// default is used
+	Compression coding.Token
+	// NoAuto is a set of automatic headers, which must not be set
+	NoAuto AutoHeader
+	// Omit is a list of the session's default headers, which must not be set
+	Omit []string
+	ctx  context.Context
+	err  error
+	// ownsFile reports, whether the File is opened by WithFile
+	ownsFile bool
 }
 
 func NewRequest(hdrs *headers.Headers) *Request {
@@ -36,6 +73,40 @@ func (r *Request) WithPath(path string) *Request {
 	return r
 }
 
+// WithQuery appends the encoded query to the path. If the path already contains a
+// query, they're merged. Keys are sorted, so the resulting path is deterministic
+func (r *Request) WithQuery(q query.Query) *Request {
+	if len(q) == 0 {
+		return r
+	}
+
+	r.Path = string(q.AppendEncoded(r.querySeparator()))
+	return r
+}
+
+// WithQueryParam appends a single key-value pair to the query of the path
+func (r *Request) WithQueryParam(key, value string) *Request {
+	path := query.AppendEscaped(r.querySeparator(), key)
+	path = append(path, '=')
+	r.Path = string(query.AppendEscaped(path, value))
+	return r
+}
+
+// querySeparator returns the path with appended separator, so the query pair can be
+// added right after it
+func (r *Request) querySeparator() []byte {
+	path := []byte(r.Path)
+
+	switch question := strings.IndexByte(r.Path, '?'); {
+	case question == -1:
+		return append(path, '?')
+	case question == len(r.Path)-1, r.Path[len(r.Path)-1] == '&':
+		return path
+	default:
+		return append(path, '&')
+	}
+}
+
 func (r *Request) WithProtocol(proto protocol.Protocol) *Request {
 	r.Proto = proto
 	return r
@@ -46,9 +117,20 @@ func (r *Request) WithHeader(key string, values ...string) *Request {
 	return r
 }
 
-// WithFile opens a new file with os.O_RDONLY flag and perm=0
+// WithoutHeader prevents the session's default headers with such keys from being set.
+// Headers, added to the request itself, aren't affected
+func (r *Request) WithoutHeader(keys ...string) *Request {
+	r.Omit = append(r.Omit, keys...)
+	return r
+}
+
+// WithFile opens a new file with os.O_RDONLY flag and perm=0. The file isn't closed
+// after being sent, as the request may be retried, so it stays open till Close or
+// WithClear is called
 func (r *Request) WithFile(filename string) *Request {
+	_ = r.Close()
 	r.File, r.err = os.OpenFile(filename, os.O_RDONLY, 0)
+	r.ownsFile = r.err == nil
 	return r
 }
 
@@ -61,25 +143,110 @@ func (r *Request) WithBodyBytes(body []byte) *Request {
 	return r
 }
 
+// WithBodyFrom streams the body from the reader. If it has a Len() int method, like
+// bytes.Reader or strings.Reader do, the body is sent with Content-Length. Otherwise,
+// chunked transfer encoding is used
 func (r *Request) WithBodyFrom(reader io.Reader) *Request {
-	r.Body, r.err = io.ReadAll(reader)
+	r.Reader = reader
 	return r
 }
 
+// WithJSON encodes the v as JSON body and sets the Content-Type header. Encoding error,
+// if any, is returned by Error
+func (r *Request) WithJSON(v any) *Request {
+	return r.WithEncoded("application/json", codec.JSON{}, v)
+}
+
+// WithEncoded encodes the v by the codec and sets the Content-Type header to the passed
+// one. Encoding error, if any, is returned by Error
+func (r *Request) WithEncoded(contentType string, c codec.Codec, v any) *Request {
+	var buff bytes.Buffer
+	if r.err = c.Encode(&buff, v); r.err != nil {
+		return r
+	}
+
+	return r.
+		WithHeader("Content-Type", contentType).
+		WithBodyBytes(buff.Bytes())
+}
+
+// WithForm encodes the form as an application/x-www-form-urlencoded body and sets
+// the Content-Type header
+func (r *Request) WithForm(form query.Query) *Request {
+	return r.
+		WithHeader("Content-Type", "application/x-www-form-urlencoded").
+		WithBodyBytes(form.AppendEncoded(nil))
+}
+
+// WithMultipart streams the form as the body and sets the Content-Type header with
+// the form's boundary
+func (r *Request) WithMultipart(form *multipart.Form) *Request {
+	return r.
+		WithHeader("Content-Type", form.ContentType()).
+		WithBodyFrom(form.Reader())
+}
+
+// WithCompression compresses the body using the coding, registered in the session's
+// coding.Manager. Content-Encoding and Content-Length headers are set automatically.
+// Pass "identity" to disable the compression, enabled by default for the session
+func (r *Request) WithCompression(token coding.Token) *Request {
+	r.Compression = token
+	return r
+}
+
+// WithoutAuto disables automatic headers, e.g. WithoutAuto(AutoHost | AutoContentLength).
+// Headers, set explicitly, are rendered anyway
+func (r *Request) WithoutAuto(headers AutoHeader) *Request {
+	r.NoAuto |= headers
+	return r
+}
+
+// WithContext sets the context of the request. It bounds waiting for the rate limiter,
+// dialing and between retries. It may also carry the trace, see trace.WithClientTrace
+func (r *Request) WithContext(ctx context.Context) *Request {
+	r.ctx = ctx
+	return r
+}
+
+// Context returns the context of the request. If none was set, context.Background is
+// returned
+func (r *Request) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+
+	return r.ctx
+}
+
 // Error returns error, if occurred during request building. This may be caused
-// by non-existing filename, passed via File, or BodyFrom, if error occurred during
-// reading from it
+// by non-existing filename, passed via File, or a value, that can't be encoded
 func (r *Request) Error() error {
 	return r.err
 }
 
+// Close closes the file, opened by WithFile. Files, set directly, are left untouched
+func (r *Request) Close() error {
+	if !r.ownsFile {
+		return nil
+	}
+
+	r.ownsFile = false
+	return r.File.Close()
+}
+
 func (r *Request) WithClear() *Request {
+	_ = r.Close()
 	r.Method = method.Unknown
 	r.Path = ""
 	r.Proto = protocol.Auto
 	r.Headers.Clear()
 	r.File = nil
+	r.Reader = nil
 	r.Body = nil
+	r.Compression = ""
+	r.NoAuto = 0
+	r.Omit = r.Omit[:0]
+	r.ctx = nil
 	r.err = nil
 	return r
 }
@@ -0,0 +1,76 @@
+package http
+
+import (
+	"github.com/indigo-web/client/http/headers"
+	"github.com/indigo-web/client/http/query"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRequest(t *testing.T) {
+	request := NewRequest(headers.NewHeaders())
+
+	t.Run("query", func(t *testing.T) {
+		request.WithClear().
+			WithPath("/search").
+			WithQuery(query.Query{"q": {"hello world"}, "lang": {"en"}}).
+			WithQueryParam("page", "2")
+		require.Equal(t, "/search?lang=en&q=hello%20world&page=2", request.Path)
+	})
+
+	t.Run("merge query", func(t *testing.T) {
+		request.WithClear().
+			WithPath("/search?q=1").
+			WithQuery(query.Query{"q": {"2"}})
+		require.Equal(t, "/search?q=1&q=2", request.Path)
+
+		request.WithClear().
+			WithPath("/search?").
+			WithQueryParam("q", "1")
+		require.Equal(t, "/search?q=1", request.Path)
+	})
+
+	t.Run("form", func(t *testing.T) {
+		request.WithClear().WithForm(query.Query{"grant_type": {"password"}, "user": {"a&b c"}})
+		require.Equal(t, "grant_type=password&user=a%26b%20c", string(request.Body))
+		require.Equal(t, "application/x-www-form-urlencoded", request.Headers.Value("content-type"))
+	})
+
+	t.Run("json", func(t *testing.T) {
+		request.WithClear().WithJSON(map[string]int{"a": 1})
+		require.NoError(t, request.Error())
+		require.Equal(t, "{\"a\":1}\n", string(request.Body))
+		require.Equal(t, "application/json", request.Headers.Value("content-type"))
+
+		request.WithClear().WithJSON(make(chan int))
+		require.Error(t, request.Error())
+	})
+
+	t.Run("file", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "sample.txt")
+		require.NoError(t, os.WriteFile(filename, []byte("hello"), 0644))
+
+		request.WithClear().WithFile(filename)
+		require.NoError(t, request.Error())
+		file := request.File
+		require.NoError(t, request.Close())
+		_, err := file.Stat()
+		require.ErrorIs(t, err, os.ErrClosed)
+
+		request.WithClear().WithFile(filename)
+		file = request.File
+		request.WithClear()
+		_, err = file.Stat()
+		require.ErrorIs(t, err, os.ErrClosed)
+
+		file, err = os.Open(filename)
+		require.NoError(t, err)
+		defer file.Close()
+		request.WithClear().File = file
+		request.WithClear()
+		_, err = file.Stat()
+		require.NoError(t, err)
+	})
+}
@@ -1,9 +1,14 @@
 package http
 
 import (
+	"github.com/indigo-web/client/http/codec"
+	"github.com/indigo-web/client/http/coding"
 	"github.com/indigo-web/client/http/headers"
 	"github.com/indigo-web/client/http/protocol"
 	"github.com/indigo-web/client/http/status"
+	"github.com/indigo-web/client/settings"
+	"strconv"
+	"time"
 )
 
 // headersPreAlloc defines a number of headers pairs, space for which will be
@@ -19,18 +24,58 @@ type Response struct {
 	ContentType   string
 	Encoding      Encoding
 	Body          *Body
+	// Trailers are populated only after the chunked body is completely read. Before
+	// that, they stay empty
+	Trailers *headers.Headers
 }
 
-func NewResponse(bodyReader BodyReader) *Response {
+func NewResponse(
+	bodyReader BodyReader, codings coding.Manager, codecs codec.Manager, s settings.Body,
+) *Response {
 	return &Response{
-		Headers: headers.NewPreallocHeaders(headersPreAlloc),
-		Body:    NewBody(bodyReader),
+		Headers:  headers.NewPreallocHeaders(headersPreAlloc),
+		Body:     NewBody(bodyReader, codings, codecs, s),
+		Trailers: headers.NewHeaders(),
 	}
 }
 
 func (r *Response) Clear() {
+	r.Proto = ""
 	r.Code = 0
+	r.Status = ""
 	r.Headers.Clear()
 	r.ContentLength = 0
+	r.ContentType = ""
 	r.Encoding = r.Encoding.Clear()
+	r.Trailers.Clear()
+}
+
+// RetryAfter returns the delay, requested by the server via the Retry-After header. Both
+// delta-seconds and HTTP-date forms are supported. The date in the past results in zero
+// delay. False is returned, if the header is missing or malformed
+func (r *Response) RetryAfter() (time.Duration, bool) {
+	value, found := r.Headers.Get("retry-after")
+	if !found {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	for _, layout := range httpDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return max(time.Until(date), 0), true
+		}
+	}
+
+	return 0, false
+}
+
+// httpDateLayouts are the preferred IMF-fixdate and both obsolete formats of the
+// HTTP-date (RFC 9110, 5.6.7)
+var httpDateLayouts = []string{
+	"Mon, 02 Jan 2006 15:04:05 GMT",
+	"Monday, 02-Jan-06 15:04:05 GMT",
+	"Mon Jan _2 15:04:05 2006",
 }
@@ -0,0 +1,47 @@
+package http
+
+import (
+	"github.com/indigo-web/client/http/codec"
+	"github.com/indigo-web/client/http/coding"
+	"github.com/indigo-web/client/settings"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestResponse(t *testing.T) {
+	resp := NewResponse(nil, coding.NewManager(), codec.NewManager(), settings.Body{})
+
+	t.Run("retry-after seconds", func(t *testing.T) {
+		defer resp.Clear()
+		resp.Headers.Add("Retry-After", "120")
+		delay, ok := resp.RetryAfter()
+		require.True(t, ok)
+		require.Equal(t, 2*time.Minute, delay)
+	})
+
+	t.Run("retry-after date", func(t *testing.T) {
+		defer resp.Clear()
+		date := time.Now().Add(time.Hour).UTC()
+		resp.Headers.Add("Retry-After", date.Format("Mon, 02 Jan 2006 15:04:05 GMT"))
+		delay, ok := resp.RetryAfter()
+		require.True(t, ok)
+		require.InDelta(t, time.Hour, delay, float64(2*time.Second))
+
+		resp.Headers.Clear()
+		resp.Headers.Add("Retry-After", "Sun, 06 Nov 1994 08:49:37 GMT")
+		delay, ok = resp.RetryAfter()
+		require.True(t, ok)
+		require.Zero(t, delay)
+	})
+
+	t.Run("retry-after malformed", func(t *testing.T) {
+		defer resp.Clear()
+		_, ok := resp.RetryAfter()
+		require.False(t, ok)
+
+		resp.Headers.Add("Retry-After", "soon")
+		_, ok = resp.RetryAfter()
+		require.False(t, ok)
+	})
+}
@@ -33,6 +33,10 @@ var (
 	ErrMethodNotAllowed              = NewError(MethodNotAllowed, "MethodNotAllowed")
 	ErrTooLarge                      = NewError(RequestEntityTooLarge, "too large")
 	ErrRequestEntityTooLarge         = NewError(RequestEntityTooLarge, "request entity too large")
+	ErrBodyTooLarge                  = NewError(RequestEntityTooLarge, "body exceeds the size limit")
+	ErrChunkTooLarge                 = NewError(RequestEntityTooLarge, "chunk exceeds the size limit")
+	ErrDecodedBodyTooLarge           = NewError(RequestEntityTooLarge, "decoded body exceeds the size limit")
+	ErrTrailingBodyData              = NewError(BadRequest, "data after the end of the encoded body")
 	ErrHeaderFieldsTooLarge          = NewError(HeaderFieldsTooLarge, "too large headers section")
 	ErrHeaderKeyTooLarge             = NewError(HeaderFieldsTooLarge, "too large header key")
 	ErrHeaderValueTooLarge           = NewError(HeaderFieldsTooLarge, "too large header value")
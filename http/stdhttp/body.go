@@ -0,0 +1,84 @@
+package stdhttp
+
+import (
+	"github.com/indigo-web/client/http"
+	"io"
+	nethttp "net/http"
+	"strings"
+)
+
+// responseBody adapts http.Body to the io.ReadCloser. Trailers are copied into the
+// net/http response as soon as the body is completely read
+type responseBody struct {
+	resp    *http.Response
+	netResp *nethttp.Response
+	closed  bool
+	// trailers are copied only once, even if Read is called after io.EOF again
+	trailers bool
+}
+
+func (r *responseBody) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, nethttp.ErrBodyReadAfterClose
+	}
+
+	n, err := r.resp.Body.Read(p)
+	if err == io.EOF && r.netResp.Trailer != nil && !r.trailers {
+		r.trailers = true
+
+		for trailersIter := r.resp.Trailers.Iter(); ; {
+			pair, cont := trailersIter.Next()
+			if !cont {
+				break
+			}
+
+			// trailers point into the buffer, which is reused by the next response
+			r.netResp.Trailer.Add(strings.Clone(pair.Key), strings.Clone(pair.Value))
+		}
+	}
+
+	return n, err
+}
+
+func (r *responseBody) Close() error {
+	if r.closed {
+		return nil
+	}
+
+	r.closed = true
+	return r.resp.Body.Close()
+}
+
+// readerBodyBuffSize is a size of the buffer, the net/http response body is read into
+const readerBodyBuffSize = 4 * 1024
+
+// readerBody adapts the net/http response body to the http.BodyReader
+type readerBody struct {
+	src  io.ReadCloser
+	buff []byte
+}
+
+func (r *readerBody) Init(*http.Response) {}
+
+func (r *readerBody) Read() ([]byte, error) {
+	if r.src == nil {
+		return nil, io.EOF
+	}
+
+	if r.buff == nil {
+		r.buff = make([]byte, readerBodyBuffSize)
+	}
+
+	for {
+		n, err := r.src.Read(r.buff)
+		if n > 0 {
+			return r.buff[:n], nil
+		}
+
+		if err != nil {
+			_ = r.src.Close()
+			r.src = nil
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package stdhttp
+
+import (
+	"github.com/indigo-web/client/http"
+	"github.com/indigo-web/client/http/codec"
+	"github.com/indigo-web/client/http/coding"
+	"github.com/indigo-web/client/http/headers"
+	"github.com/indigo-web/client/http/status"
+	"github.com/indigo-web/client/settings"
+	nethttp "net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FromHeader converts net/http headers. As maps are unordered, keys are sorted, so the
+// result is deterministic. Values of the same key keep their order
+func FromHeader(header nethttp.Header) *headers.Headers {
+	hdrs := headers.NewPreallocHeaders(len(header))
+	for _, key := range sortedKeys(header) {
+		hdrs.Add(key, header[key]...)
+	}
+
+	return hdrs
+}
+
+// ToHeader converts headers into net/http ones. Keys are canonicalized, and values of
+// the same key are grouped together in the order of their appearance. Keys and values
+// are copied, as parsed headers point into buffers, which are reused by the next response
+func ToHeader(hdrs *headers.Headers) nethttp.Header {
+	header := make(nethttp.Header, len(hdrs.Unwrap())/2)
+	for headersIter := hdrs.Iter(); ; {
+		pair, cont := headersIter.Next()
+		if !cont {
+			break
+		}
+
+		header.Add(strings.Clone(pair.Key), strings.Clone(pair.Value))
+	}
+
+	return header
+}
+
+// FromRequest fills the request from the net/http one. The body isn't read, but is
+// streamed from the original request instead
+func FromRequest(request *http.Request, req *nethttp.Request) *http.Request {
+	method := req.Method
+	if len(method) == 0 {
+		method = nethttp.MethodGet
+	}
+
+	request.
+		WithMethod(method).
+		WithPath(req.URL.RequestURI()).
+		WithContext(req.Context())
+
+	for _, key := range sortedKeys(req.Header) {
+		request.WithHeader(key, req.Header[key]...)
+	}
+
+	if len(req.Host) > 0 && req.Host != req.URL.Host {
+		request.WithHeader("Host", req.Host)
+	}
+
+	if req.Body != nil && req.Body != nethttp.NoBody {
+		request.WithBodyFrom(req.Body)
+
+		if req.ContentLength > 0 {
+			request.WithHeader("Content-Length", strconv.FormatInt(req.ContentLength, 10))
+		}
+	}
+
+	return request
+}
+
+// ToResponse converts the response into the net/http one. Its body streams from the
+// http.Body, so the response must not be reused until the body is closed. Declared
+// trailers are populated as soon as the body is completely read
+func ToResponse(resp *http.Response, req *nethttp.Request) *nethttp.Response {
+	header := ToHeader(resp.Headers)
+
+	reason := resp.Status
+	if len(reason) == 0 {
+		reason = status.Text(resp.Code)
+	}
+
+	major, minor, ok := nethttp.ParseHTTPVersion(resp.Proto)
+	if !ok {
+		major, minor = 1, 1
+	}
+
+	netResp := &nethttp.Response{
+		Status:        strconv.Itoa(int(resp.Code)) + " " + reason,
+		StatusCode:    int(resp.Code),
+		Proto:         resp.Proto,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        header,
+		ContentLength: int64(resp.ContentLength),
+		Request:       req,
+	}
+
+	if resp.Encoding.Chunked {
+		netResp.ContentLength = -1
+		netResp.TransferEncoding = []string{"chunked"}
+		header.Del("Transfer-Encoding")
+	}
+
+	if resp.Body.Decoded() {
+		// the body is decoded transparently, so the length and coding don't match it anymore
+		netResp.ContentLength = -1
+		netResp.Uncompressed = true
+		header.Del("Content-Encoding")
+		header.Del("Content-Length")
+	}
+
+	if len(resp.Encoding.Trailer) > 0 {
+		netResp.Trailer = make(nethttp.Header, len(resp.Encoding.Trailer))
+		for _, key := range resp.Encoding.Trailer {
+			netResp.Trailer[nethttp.CanonicalHeaderKey(strings.Clone(key))] = nil
+		}
+	}
+
+	netResp.Body = &responseBody{
+		resp:    resp,
+		netResp: netResp,
+	}
+
+	return netResp
+}
+
+// FromResponse converts the net/http response. The body is streamed from the original
+// one, and is decoded by the default codings, if it wasn't already by net/http
+func FromResponse(netResp *nethttp.Response) *http.Response {
+	resp := http.NewResponse(
+		&readerBody{src: netResp.Body},
+		coding.NewDefaultManager(),
+		codec.NewDefaultManager(),
+		settings.Body{},
+	)
+	resp.Proto = netResp.Proto
+	resp.Code = status.Code(netResp.StatusCode)
+	resp.Status = strings.TrimSpace(strings.TrimPrefix(netResp.Status, strconv.Itoa(netResp.StatusCode)))
+	resp.ContentLength = int(max(netResp.ContentLength, 0))
+	resp.ContentType = netResp.Header.Get("Content-Type")
+
+	for _, key := range sortedKeys(netResp.Header) {
+		resp.Headers.Add(key, netResp.Header[key]...)
+	}
+
+	if !netResp.Uncompressed {
+		for _, value := range netResp.Header.Values("Content-Encoding") {
+			resp.Encoding.Content = append(resp.Encoding.Content, splitTokens(value)...)
+		}
+	}
+
+	for _, te := range netResp.TransferEncoding {
+		resp.Encoding.Transfer = append(resp.Encoding.Transfer, te)
+		resp.Encoding.Chunked = resp.Encoding.Chunked || strings.EqualFold(te, "chunked")
+	}
+
+	for _, key := range sortedKeys(netResp.Trailer) {
+		resp.Encoding.Trailer = append(resp.Encoding.Trailer, key)
+		resp.Encoding.HasTrailer = true
+	}
+
+	resp.Body.Init(resp)
+
+	return resp
+}
+
+func sortedKeys(header nethttp.Header) []string {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func splitTokens(value string) (tokens []string) {
+	for _, token := range strings.Split(value, ",") {
+		if token = strings.TrimSpace(token); len(token) > 0 {
+			tokens = append(tokens, token)
+		}
+	}
+
+	return tokens
+}
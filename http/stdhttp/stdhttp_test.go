@@ -0,0 +1,143 @@
+package stdhttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/indigo-web/client/http"
+	"github.com/indigo-web/client/http/codec"
+	"github.com/indigo-web/client/http/coding"
+	"github.com/indigo-web/client/http/headers"
+	"github.com/indigo-web/client/internal/parser/http1"
+	"github.com/indigo-web/client/internal/tcp"
+	"github.com/indigo-web/client/settings"
+	"github.com/indigo-web/utils/buffer"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	nethttp "net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeaders(t *testing.T) {
+	hdrs := headers.NewHeaders()
+	hdrs.Add("x-values", "1")
+	hdrs.Add("Content-Type", "text/plain")
+	hdrs.Add("X-Values", "2", "3")
+
+	header := ToHeader(hdrs)
+	require.Equal(t, []string{"1", "2", "3"}, header.Values("X-Values"))
+	require.Equal(t, "text/plain", header.Get("Content-Type"))
+
+	back := FromHeader(header)
+	require.Equal(t, []string{"Content-Type", "text/plain", "X-Values", "1", "X-Values", "2", "X-Values", "3"}, back.Unwrap())
+}
+
+func TestRequest(t *testing.T) {
+	req, err := nethttp.NewRequest(nethttp.MethodPut, "http://localhost:8080/path?q=1", strings.NewReader("body"))
+	require.NoError(t, err)
+	req.Header.Add("B", "1")
+	req.Header.Add("A", "1")
+	req.Header.Add("A", "2")
+	req.Host = "example.com"
+
+	request := FromRequest(http.NewRequest(headers.NewHeaders()), req)
+	require.Equal(t, "PUT", request.Method)
+	require.Equal(t, "/path?q=1", request.Path)
+	require.Equal(t, []string{"A", "1", "A", "2", "B", "1", "Host", "example.com", "Content-Length", "4"}, request.Headers.Unwrap())
+	body, err := io.ReadAll(request.Reader)
+	require.NoError(t, err)
+	require.Equal(t, "body", string(body))
+}
+
+func TestResponse(t *testing.T) {
+	t.Run("from and to", func(t *testing.T) {
+		var encoded bytes.Buffer
+		gz := gzip.NewWriter(&encoded)
+		_, _ = gz.Write([]byte("hello"))
+		require.NoError(t, gz.Close())
+
+		netResp := &nethttp.Response{
+			Status:     "201 Created",
+			StatusCode: 201,
+			Proto:      "HTTP/1.1",
+			Header: nethttp.Header{
+				"Content-Encoding": {"gzip"},
+				"Content-Type":     {"text/plain"},
+				"Set-Cookie":       {"a=1", "b=2"},
+			},
+			ContentLength: int64(encoded.Len()),
+			Body:          io.NopCloser(&encoded),
+		}
+
+		resp := FromResponse(netResp)
+		require.Equal(t, 201, int(resp.Code))
+		require.Equal(t, "Created", resp.Status)
+		require.Equal(t, "text/plain", resp.ContentType)
+		require.Equal(t, []string{"a=1", "b=2"}, resp.Headers.Values("set-cookie"))
+		require.Equal(t, []string{"gzip"}, resp.Encoding.Content)
+
+		back := ToResponse(resp, nil)
+		require.Equal(t, "201 Created", back.Status)
+		require.Equal(t, []string{"a=1", "b=2"}, back.Header.Values("Set-Cookie"))
+		require.True(t, back.Uncompressed)
+		require.Empty(t, back.Header.Get("Content-Encoding"))
+		body, err := io.ReadAll(back.Body)
+		require.NoError(t, err)
+		require.Equal(t, "hello", string(body))
+		require.NoError(t, back.Body.Close())
+		_, err = back.Body.Read(make([]byte, 1))
+		require.ErrorIs(t, err, nethttp.ErrBodyReadAfterClose)
+	})
+
+	t.Run("reused buffers", func(t *testing.T) {
+		server, conn := net.Pipe()
+		defer server.Close()
+		defer conn.Close()
+
+		go func() {
+			for _, id := range []string{"AAAAAAAA", "BBBBBBBB"} {
+				_, _ = server.Write([]byte(
+					"HTTP/1.1 200 OK\r\nX-Id: " + id + "\r\nTransfer-Encoding: chunked\r\nTrailer: X-Sum\r\n\r\n" +
+						"2\r\nhi\r\n0\r\nX-Sum: " + strings.ToLower(id) + "\r\n\r\n",
+				))
+			}
+		}()
+
+		s := settings.Default().Body
+		client := tcp.NewClient(conn, time.Second, time.Second, make([]byte, 4096))
+		body := http1.NewBody(client, http1.NewChunkedParser(*buffer.NewBuffer[byte](0, 4096), 0), s)
+		resp := http.NewResponse(body, coding.NewManager(), codec.NewManager(), s)
+		parser := http1.NewParser(resp, *buffer.NewBuffer[byte](0, 1024), *buffer.NewBuffer[byte](0, 4096))
+
+		next := func() *nethttp.Response {
+			resp.Clear()
+			parser.Release()
+
+			for {
+				data, err := client.Read()
+				require.NoError(t, err)
+				done, rest, err := parser.Parse(data)
+				require.NoError(t, err)
+				client.Unread(rest)
+
+				if done {
+					resp.Body.Init(resp)
+					netResp := ToResponse(resp, nil)
+					_, err = io.ReadAll(netResp.Body)
+					require.NoError(t, err)
+
+					return netResp
+				}
+			}
+		}
+
+		first := next()
+		second := next()
+		require.Equal(t, "BBBBBBBB", second.Header.Get("X-Id"))
+		require.Equal(t, "bbbbbbbb", second.Trailer.Get("X-Sum"))
+		require.Equal(t, "AAAAAAAA", first.Header.Get("X-Id"))
+		require.Equal(t, "aaaaaaaa", first.Trailer.Get("X-Sum"))
+	})
+}
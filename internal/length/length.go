@@ -0,0 +1,29 @@
+package length
+
+import (
+	"io"
+	"os"
+)
+
+// Of returns the number of bytes left in the reader, or -1 if it's unknown. It's known
+// for regular files and readers with Len() int method, like bytes.Reader
+func Of(src io.Reader) int64 {
+	switch src := src.(type) {
+	case *os.File:
+		stat, err := src.Stat()
+		if err != nil || !stat.Mode().IsRegular() {
+			return -1
+		}
+
+		offset, err := src.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+
+		return stat.Size() - offset
+	case interface{ Len() int }:
+		return int64(src.Len())
+	}
+
+	return -1
+}
@@ -1,9 +1,10 @@
 package http1
 
 import (
-	"github.com/indigo-web/chunkedbody"
 	"github.com/indigo-web/client/http"
+	"github.com/indigo-web/client/http/status"
 	"github.com/indigo-web/client/internal/tcp"
+	"github.com/indigo-web/client/settings"
 	"io"
 )
 
@@ -15,12 +16,15 @@ type Body struct {
 	client        tcp.Client
 	encoding      http.Encoding
 	bytesLeft     bodyBytesLeft
-	chunkedParser *chunkedbody.Parser
+	received      int64
+	maxSize       int64
+	chunkedParser *ChunkedParser
 }
 
-func NewBody(client tcp.Client, parser *chunkedbody.Parser) *Body {
+func NewBody(client tcp.Client, parser *ChunkedParser, s settings.Body) *Body {
 	return &Body{
 		client:        client,
+		maxSize:       s.MaxSize,
 		chunkedParser: parser,
 	}
 }
@@ -28,14 +32,25 @@ func NewBody(client tcp.Client, parser *chunkedbody.Parser) *Body {
 func (b *Body) Init(response *http.Response) {
 	b.encoding = response.Encoding
 	b.bytesLeft = response.ContentLength
+	b.received = 0
+	response.Trailers.Clear()
+
+	if response.Encoding.Chunked {
+		b.bytesLeft = chunked
+		b.chunkedParser.Init(response)
+	}
 }
 
 func (b *Body) Read() ([]byte, error) {
-	// TODO: implement decoding
 	if b.bytesLeft == 0 {
 		return nil, io.EOF
 	}
 
+	if b.bytesLeft != chunked && b.tooLarge(int64(b.bytesLeft)) {
+		// no need to read a body, that is known to be too large in advance
+		return nil, status.ErrBodyTooLarge
+	}
+
 	data, err := b.client.Read()
 	if err != nil {
 		return nil, err
@@ -49,7 +64,7 @@ func (b *Body) Read() ([]byte, error) {
 }
 
 func (b *Body) readChunked(data []byte) ([]byte, error) {
-	chunk, extra, err := b.chunkedParser.Parse(data, b.encoding.HasTrailer)
+	chunk, extra, err := b.chunkedParser.Parse(data)
 	switch err {
 	case nil:
 	case io.EOF:
@@ -59,6 +74,10 @@ func (b *Body) readChunked(data []byte) ([]byte, error) {
 	}
 
 	b.client.Unread(extra)
+	b.received += int64(len(chunk))
+	if b.tooLarge(0) {
+		return nil, status.ErrBodyTooLarge
+	}
 
 	return chunk, nil
 }
@@ -76,3 +95,9 @@ func (b *Body) readPlain(data []byte) ([]byte, error) {
 
 	return body, nil
 }
+
+// tooLarge reports, whether the body exceeds the size limit, after n more bytes are
+// received
+func (b *Body) tooLarge(n int64) bool {
+	return b.maxSize > 0 && b.received+n > b.maxSize
+}
@@ -0,0 +1,78 @@
+package http1
+
+import (
+	"github.com/indigo-web/client/http"
+	"github.com/indigo-web/client/http/codec"
+	"github.com/indigo-web/client/http/coding"
+	"github.com/indigo-web/client/http/status"
+	"github.com/indigo-web/client/internal/tcp"
+	"github.com/indigo-web/client/settings"
+	"github.com/indigo-web/utils/buffer"
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBodyLimits(t *testing.T) {
+	newBody := func(t *testing.T, data string, s settings.Body) *Body {
+		server, conn := net.Pipe()
+		t.Cleanup(func() {
+			_ = server.Close()
+			_ = conn.Close()
+		})
+
+		go func() {
+			_, _ = server.Write([]byte(data))
+		}()
+
+		client := tcp.NewClient(conn, time.Second, time.Second, make([]byte, 16))
+		return NewBody(client, NewChunkedParser(*buffer.NewBuffer[byte](0, 4096), s.MaxChunkSize), s)
+	}
+
+	newResponse := func() *http.Response {
+		return http.NewResponse(nil, coding.NewManager(), codec.NewManager(), settings.Body{})
+	}
+
+	t.Run("content length", func(t *testing.T) {
+		body := newBody(t, "", settings.Body{MaxSize: 10})
+		resp := newResponse()
+		resp.ContentLength = 11
+		body.Init(resp)
+		_, err := body.Read()
+		require.EqualError(t, err, status.ErrBodyTooLarge.Error())
+	})
+
+	t.Run("chunked", func(t *testing.T) {
+		body := newBody(t, "8\r\n01234567\r\n8\r\n01234567\r\n0\r\n\r\n", settings.Body{MaxSize: 10})
+		resp := newResponse()
+		resp.Encoding.Chunked = true
+		body.Init(resp)
+
+		for {
+			_, err := body.Read()
+			if err != nil {
+				require.EqualError(t, err, status.ErrBodyTooLarge.Error())
+				break
+			}
+		}
+	})
+
+	t.Run("within limits", func(t *testing.T) {
+		body := newBody(t, "0123456789", settings.Body{MaxSize: 10})
+		resp := newResponse()
+		resp.ContentLength = 10
+		body.Init(resp)
+
+		var received []byte
+		for {
+			data, err := body.Read()
+			if err != nil {
+				require.Equal(t, "0123456789", string(received))
+				break
+			}
+
+			received = append(received, data...)
+		}
+	})
+}
@@ -0,0 +1,304 @@
+package http1
+
+import (
+	"bytes"
+	"github.com/indigo-web/client/http"
+	"github.com/indigo-web/client/http/status"
+	"github.com/indigo-web/utils/buffer"
+	"github.com/indigo-web/utils/strcomp"
+	"github.com/indigo-web/utils/uf"
+	"io"
+)
+
+// maxChunkLength limits the chunk length, so it won't overflow int64 while parsing.
+// It's roughly 1 exabyte, so hardly any legit chunk will ever reach it
+const maxChunkLength = 1 << 59
+
+// ChunkedParser parses a chunked body. Unlike just skipping the trailer section, it
+// collects trailer fields into the http.Response.Trailers
+type ChunkedParser struct {
+	state        chunkedState
+	chunkLength  int64
+	maxChunkSize int64
+	response     *http.Response
+	trailerBuff  buffer.Buffer[byte]
+	trailerKey   string
+	// dropTrailer is set, if the trailer field being parsed wasn't declared
+	dropTrailer bool
+}
+
+// NewChunkedParser returns a new parser. Zero maxChunkSize means chunks are limited
+// only by maxChunkLength
+func NewChunkedParser(trailerBuff buffer.Buffer[byte], maxChunkSize int64) *ChunkedParser {
+	if maxChunkSize <= 0 || maxChunkSize > maxChunkLength {
+		maxChunkSize = maxChunkLength
+	}
+
+	return &ChunkedParser{
+		state:        eChunkLength,
+		maxChunkSize: maxChunkSize,
+		trailerBuff:  trailerBuff,
+	}
+}
+
+// Init prepares the parser for a new response body
+func (c *ChunkedParser) Init(response *http.Response) {
+	c.state = eChunkLength
+	c.chunkLength = 0
+	c.response = response
+	c.trailerBuff.Clear()
+}
+
+// Parse returns a piece of the chunk data and the rest of the data, that doesn't belong
+// to the chunk (so must be fed into the parser again). io.EOF is returned as soon as
+// the whole body, including trailers, is parsed
+func (c *ChunkedParser) Parse(data []byte) (chunk, extra []byte, err error) {
+	var offset int
+
+	switch c.state {
+	case eChunkLength:
+		goto chunkLength
+	case eChunkLengthCR:
+		goto chunkLengthCR
+	case eChunkExt:
+		goto chunkExt
+	case eChunkBody:
+		goto chunkBody
+	case eChunkBodyEnd:
+		goto chunkBodyEnd
+	case eChunkBodyCR:
+		goto chunkBodyCR
+	case eLastChunk:
+		goto lastChunk
+	case eLastChunkCR:
+		goto lastChunkCR
+	case eTrailerKey:
+		goto trailerKey
+	case eTrailerSemicolon:
+		goto trailerSemicolon
+	case eTrailerValue:
+		goto trailerValue
+	default:
+		panic("BUG: chunked parser: unknown state")
+	}
+
+chunkLength:
+	for ; offset < len(data); offset++ {
+		var digit int64
+
+		switch char := data[offset]; {
+		case char >= '0' && char <= '9':
+			digit = int64(char - '0')
+		case char >= 'a' && char <= 'f':
+			digit = int64(char - 'a' + 10)
+		case char >= 'A' && char <= 'F':
+			digit = int64(char - 'A' + 10)
+		case char == '\r':
+			offset++
+			c.state = eChunkLengthCR
+			goto chunkLengthCR
+		case char == '\n':
+			offset++
+			goto chunkLengthEnd
+		case char == ';' || char == ' ' || char == '\t':
+			offset++
+			c.state = eChunkExt
+			goto chunkExt
+		default:
+			return nil, nil, status.ErrBadRequest
+		}
+
+		// checked before shifting, as otherwise the length might overflow
+		if c.chunkLength > c.maxChunkSize>>4 {
+			return nil, nil, status.ErrChunkTooLarge
+		}
+
+		c.chunkLength = c.chunkLength<<4 | digit
+		if c.chunkLength > c.maxChunkSize {
+			return nil, nil, status.ErrChunkTooLarge
+		}
+	}
+
+	return nil, nil, nil
+
+chunkLengthCR:
+	if offset >= len(data) {
+		return nil, nil, nil
+	}
+
+	if data[offset] != '\n' {
+		return nil, nil, status.ErrBadRequest
+	}
+
+	offset++
+	goto chunkLengthEnd
+
+chunkExt:
+	// chunk extensions are not supported, so just skipped
+	{
+		lf := bytes.IndexByte(data[offset:], '\n')
+		if lf == -1 {
+			return nil, nil, nil
+		}
+
+		offset += lf + 1
+		goto chunkLengthEnd
+	}
+
+chunkLengthEnd:
+	if c.chunkLength == 0 {
+		c.state = eLastChunk
+		goto lastChunk
+	}
+
+	c.state = eChunkBody
+	goto chunkBody
+
+chunkBody:
+	{
+		data = data[offset:]
+		if int64(len(data)) < c.chunkLength {
+			c.chunkLength -= int64(len(data))
+
+			return data, nil, nil
+		}
+
+		chunk, extra = data[:c.chunkLength], data[c.chunkLength:]
+		c.chunkLength = 0
+		c.state = eChunkBodyEnd
+
+		return chunk, extra, nil
+	}
+
+chunkBodyEnd:
+	if offset >= len(data) {
+		return nil, nil, nil
+	}
+
+	switch data[offset] {
+	case '\r':
+		offset++
+		c.state = eChunkBodyCR
+		goto chunkBodyCR
+	case '\n':
+		offset++
+		c.state = eChunkLength
+		goto chunkLength
+	default:
+		return nil, nil, status.ErrBadRequest
+	}
+
+chunkBodyCR:
+	if offset >= len(data) {
+		return nil, nil, nil
+	}
+
+	if data[offset] != '\n' {
+		return nil, nil, status.ErrBadRequest
+	}
+
+	offset++
+	c.state = eChunkLength
+	goto chunkLength
+
+lastChunk:
+	if offset >= len(data) {
+		return nil, nil, nil
+	}
+
+	switch data[offset] {
+	case '\r':
+		offset++
+		c.state = eLastChunkCR
+		goto lastChunkCR
+	case '\n':
+		c.state = eChunkLength
+		return nil, data[offset+1:], io.EOF
+	}
+
+	c.state = eTrailerKey
+	goto trailerKey
+
+lastChunkCR:
+	if offset >= len(data) {
+		return nil, nil, nil
+	}
+
+	if data[offset] != '\n' {
+		return nil, nil, status.ErrBadRequest
+	}
+
+	c.state = eChunkLength
+	return nil, data[offset+1:], io.EOF
+
+trailerKey:
+	{
+		semicolon := bytes.IndexByte(data[offset:], ':')
+		if semicolon == -1 {
+			if !c.trailerBuff.Append(data[offset:]...) {
+				return nil, nil, status.ErrHeaderKeyTooLarge
+			}
+
+			return nil, nil, nil
+		}
+
+		if !c.trailerBuff.Append(data[offset : offset+semicolon]...) {
+			return nil, nil, status.ErrHeaderKeyTooLarge
+		}
+
+		c.trailerKey = uf.B2S(c.trailerBuff.Finish())
+		// undeclared fields are ignored rather than rejected, as the body itself is
+		// still fine, however they mustn't be trusted
+		c.dropTrailer = !c.isDeclared(c.trailerKey)
+
+		offset += semicolon + 1
+		c.state = eTrailerSemicolon
+		goto trailerSemicolon
+	}
+
+trailerSemicolon:
+	for ; offset < len(data); offset++ {
+		if data[offset] != ' ' {
+			c.state = eTrailerValue
+			goto trailerValue
+		}
+	}
+
+	return nil, nil, nil
+
+trailerValue:
+	{
+		lf := bytes.IndexByte(data[offset:], '\n')
+		if lf == -1 {
+			if !c.trailerBuff.Append(data[offset:]...) {
+				return nil, nil, status.ErrHeaderValueTooLarge
+			}
+
+			return nil, nil, nil
+		}
+
+		if !c.trailerBuff.Append(data[offset : offset+lf]...) {
+			return nil, nil, status.ErrHeaderValueTooLarge
+		}
+
+		value := uf.B2S(rstripCR(c.trailerBuff.Finish()))
+		if !c.dropTrailer {
+			c.response.Trailers.Add(c.trailerKey, value)
+		}
+
+		offset += lf + 1
+		c.state = eLastChunk
+		goto lastChunk
+	}
+}
+
+// isDeclared reports, whether the trailer field was listed in the Trailer header
+func (c *ChunkedParser) isDeclared(key string) bool {
+	for _, declared := range c.response.Encoding.Trailer {
+		if strcomp.EqualFold(declared, key) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,123 @@
+package http1
+
+import (
+	"github.com/indigo-web/client/http"
+	"github.com/indigo-web/client/http/codec"
+	"github.com/indigo-web/client/http/coding"
+	"github.com/indigo-web/client/http/status"
+	"github.com/indigo-web/client/settings"
+	"github.com/indigo-web/utils/buffer"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+func parseChunked(parser *ChunkedParser, data []byte) (body []byte, err error) {
+	for {
+		chunk, extra, err := parser.Parse(data)
+		body = append(body, chunk...)
+
+		switch err {
+		case nil:
+		case io.EOF:
+			return body, nil
+		default:
+			return nil, err
+		}
+
+		if len(extra) == 0 {
+			return body, io.ErrUnexpectedEOF
+		}
+
+		data = extra
+	}
+}
+
+func TestChunkedParser(t *testing.T) {
+	resp := http.NewResponse(nil, coding.NewManager(), codec.NewManager(), settings.Body{})
+	parser := NewChunkedParser(*buffer.NewBuffer[byte](0, 4096), 0)
+
+	t.Run("no trailers", func(t *testing.T) {
+		defer resp.Clear()
+		parser.Init(resp)
+
+		data := "5\r\nHello\r\n7\r\n, world\r\n0\r\n\r\n"
+		body, err := parseChunked(parser, []byte(data))
+		require.NoError(t, err)
+		require.Equal(t, "Hello, world", string(body))
+		require.Empty(t, resp.Trailers.Unwrap())
+	})
+
+	t.Run("declared trailers", func(t *testing.T) {
+		defer resp.Clear()
+		resp.Encoding.Trailer = []string{"Grpc-Status", "Checksum"}
+		parser.Init(resp)
+
+		data := "5\r\nHello\r\n0\r\ngrpc-status: 0\r\nChecksum: abc\r\n\r\n"
+		body, err := parseChunked(parser, []byte(data))
+		require.NoError(t, err)
+		require.Equal(t, "Hello", string(body))
+		require.Equal(t, "0", resp.Trailers.Value("grpc-status"))
+		require.Equal(t, "abc", resp.Trailers.Value("checksum"))
+	})
+
+	t.Run("undeclared trailer", func(t *testing.T) {
+		defer resp.Clear()
+		resp.Encoding.Trailer = []string{"Checksum"}
+		parser.Init(resp)
+
+		data := "5\r\nHello\r\n0\r\nContent-Length: 5\r\nChecksum: abc\r\n\r\n"
+		body, err := parseChunked(parser, []byte(data))
+		require.NoError(t, err)
+		require.Equal(t, "Hello", string(body))
+		require.Equal(t, []string{"Checksum", "abc"}, resp.Trailers.Unwrap())
+	})
+
+	t.Run("too large chunk", func(t *testing.T) {
+		defer resp.Clear()
+		parser := NewChunkedParser(*buffer.NewBuffer[byte](0, 4096), 16)
+		parser.Init(resp)
+
+		_, err := parseChunked(parser, []byte("10\r\n0123456789abcdef\r\n0\r\n\r\n"))
+		require.NoError(t, err)
+		parser.Init(resp)
+		_, err = parseChunked(parser, []byte("11\r\n0123456789abcdefg\r\n0\r\n\r\n"))
+		require.EqualError(t, err, status.ErrChunkTooLarge.Error())
+	})
+
+	t.Run("overflowing chunk length", func(t *testing.T) {
+		defer resp.Clear()
+		parser.Init(resp)
+
+		_, err := parseChunked(parser, []byte("8000000000000000\r\nHello\r\n0\r\n\r\n"))
+		require.EqualError(t, err, status.ErrChunkTooLarge.Error())
+		parser.Init(resp)
+		_, err = parseChunked(parser, []byte("ffffffffffffffffff\r\nHello\r\n0\r\n\r\n"))
+		require.EqualError(t, err, status.ErrChunkTooLarge.Error())
+	})
+
+	t.Run("byte by byte", func(t *testing.T) {
+		defer resp.Clear()
+		resp.Encoding.Trailer = []string{"Checksum"}
+		parser.Init(resp)
+
+		var body []byte
+		data := "5;ext=1\r\nHello\r\n0\r\nChecksum: abc\r\n\r\n"
+
+		for i := 0; i < len(data); i++ {
+			chunk, extra, err := parser.Parse([]byte{data[i]})
+			body = append(body, chunk...)
+			require.Empty(t, extra)
+
+			if err == io.EOF {
+				require.Equal(t, len(data)-1, i)
+				break
+			}
+
+			require.NoError(t, err)
+		}
+
+		require.Equal(t, "Hello", string(body))
+		require.Equal(t, "abc", resp.Trailers.Value("checksum"))
+	})
+}
@@ -15,6 +15,10 @@ import (
 
 var _ parser.Parser = &Parser{}
 
+// maxEncodingTokens limits the number of tokens in a single Transfer-Encoding or
+// Content-Encoding header value
+const maxEncodingTokens = 16
+
 type Parser struct {
 	state        parserState
 	response     *http.Response
@@ -30,6 +34,7 @@ func NewParser(resp *http.Response, respLineBuff, headersBuff buffer.Buffer[byte
 		response:     resp,
 		respLineBuff: respLineBuff,
 		headersBuff:  headersBuff,
+		encToksBuff:  make([]string, 0, maxEncodingTokens),
 	}
 }
 
@@ -211,6 +216,7 @@ headerValue:
 			p.response.Encoding.Content = append(p.response.Encoding.Content, toks...)
 		case strcomp.EqualFold(p.headerKey, "trailer"):
 			p.response.Encoding.HasTrailer = true
+			p.response.Encoding.Trailer = splitTokens(p.response.Encoding.Trailer, value)
 			// TODO: implement upgrade header
 		}
 
@@ -274,8 +280,29 @@ func processEncodingToken(
 	return buff, false, nil
 }
 
+// splitTokens appends comma-separated tokens of the value into the buff
+func splitTokens(buff []string, value string) []string {
+	var offset int
+
+	for i := range value {
+		if value[i] == ',' {
+			if token := strings.TrimSpace(value[offset:i]); len(token) > 0 {
+				buff = append(buff, token)
+			}
+
+			offset = i + 1
+		}
+	}
+
+	if token := strings.TrimSpace(value[offset:]); len(token) > 0 {
+		buff = append(buff, token)
+	}
+
+	return buff
+}
+
 func rstripCR(b []byte) []byte {
-	if b[len(b)-1] == '\r' {
+	if len(b) > 0 && b[len(b)-1] == '\r' {
 		b = b[:len(b)-1]
 	}
 
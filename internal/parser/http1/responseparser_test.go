@@ -2,9 +2,12 @@ package http1
 
 import (
 	"github.com/indigo-web/client/http"
+	"github.com/indigo-web/client/http/codec"
+	"github.com/indigo-web/client/http/coding"
 	"github.com/indigo-web/client/http/headers"
 	"github.com/indigo-web/client/http/protocol"
 	"github.com/indigo-web/client/http/status"
+	"github.com/indigo-web/client/settings"
 	"github.com/indigo-web/utils/buffer"
 	"github.com/stretchr/testify/require"
 	"testing"
@@ -24,7 +27,7 @@ func compareResponse(t *testing.T, want, got *http.Response) {
 }
 
 func TestResponseParser(t *testing.T) {
-	resp := http.NewResponse()
+	resp := http.NewResponse(nil, coding.NewManager(), codec.NewManager(), settings.Body{})
 	parser := NewParser(
 		resp, *buffer.NewBuffer[byte](0, 4096), *buffer.NewBuffer[byte](0, 4096),
 	)
@@ -11,3 +11,19 @@ const (
 	eHeaderSemicolon
 	eHeaderValue
 )
+
+type chunkedState int
+
+const (
+	eChunkLength chunkedState = iota + 1
+	eChunkLengthCR
+	eChunkExt
+	eChunkBody
+	eChunkBodyEnd
+	eChunkBodyCR
+	eLastChunk
+	eLastChunkCR
+	eTrailerKey
+	eTrailerSemicolon
+	eTrailerValue
+)
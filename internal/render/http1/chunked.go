@@ -0,0 +1,31 @@
+package http1
+
+import (
+	"github.com/indigo-web/client/internal/tcp"
+	"strconv"
+)
+
+// chunkedWriter writes everything as chunks of chunked transfer encoding. Close
+// writes the last chunk, but doesn't close the underlying client
+type chunkedWriter struct {
+	client tcp.Client
+	buff   []byte
+}
+
+func (c *chunkedWriter) Write(p []byte) (n int, err error) {
+	if len(p) == 0 {
+		// empty chunk would be treated as the last one
+		return 0, nil
+	}
+
+	c.buff = strconv.AppendUint(c.buff[:0], uint64(len(p)), 16)
+	c.buff = append(c.buff, '\r', '\n')
+	c.buff = append(c.buff, p...)
+	c.buff = append(c.buff, '\r', '\n')
+
+	return len(p), c.client.Write(c.buff)
+}
+
+func (c *chunkedWriter) Close() error {
+	return c.client.Write([]byte("0\r\n\r\n"))
+}
@@ -1,29 +1,77 @@
 package http1
 
 import (
-	"fmt"
+	"bytes"
 	"github.com/indigo-web/client/http"
+	"github.com/indigo-web/client/http/coding"
+	"github.com/indigo-web/client/http/headers"
 	"github.com/indigo-web/client/http/method"
 	"github.com/indigo-web/client/http/protocol"
+	"github.com/indigo-web/client/internal/length"
 	"github.com/indigo-web/client/internal/tcp"
+	"github.com/indigo-web/client/settings"
+	"github.com/indigo-web/client/trace"
+	"github.com/indigo-web/utils/strcomp"
 	"io"
 	"os"
 	"strconv"
+	"strings"
 )
 
 type Renderer struct {
-	client tcp.Client
-	buff   []byte
+	client      tcp.Client
+	host        string
+	defaults    *headers.Headers
+	buff        []byte
+	codings     coding.Manager
+	settings    settings.Request
+	compression coding.Token
+	preferred   []coding.Token
+	encodeBuff  bytes.Buffer
+	copyBuff    []byte
+	chunked     chunkedWriter
 }
 
-func NewRenderer(client tcp.Client, buff []byte) *Renderer {
+func NewRenderer(
+	client tcp.Client, host string, defaults *headers.Headers, buff []byte,
+	codings coding.Manager, s settings.Request,
+) *Renderer {
 	return &Renderer{
-		client: client,
-		buff:   buff,
+		client:   client,
+		host:     host,
+		defaults: defaults,
+		buff:     buff,
+		codings:  codings,
+		settings: s,
+		chunked:  chunkedWriter{client: client},
 	}
 }
 
-func (r *Renderer) Send(request *http.Request) error {
+// SetCompression sets the coding, which will be used to compress request bodies by
+// default. Empty token or identity disables the compression
+func (r *Renderer) SetCompression(token coding.Token) {
+	r.compression = token
+}
+
+// SetPreferredEncodings sets codings, which are preferred to be used by the server
+// for response bodies. They're advertised in Accept-Encoding with higher q-values
+func (r *Renderer) SetPreferredEncodings(tokens []coding.Token) {
+	r.preferred = tokens
+}
+
+// Send renders the request and writes it. Writing stages are reported to the trace, if
+// it isn't nil
+func (r *Renderer) Send(request *http.Request, t *trace.ClientTrace) error {
+	err := r.send(request, t)
+	if t != nil && t.WroteRequest != nil {
+		t.WroteRequest(err)
+	}
+
+	return err
+}
+
+func (r *Renderer) send(request *http.Request, t *trace.ClientTrace) error {
+	r.buff = r.buff[:0]
 	r.method(request.Method)
 	r.sp()
 	r.path(request.Path)
@@ -31,39 +79,268 @@ func (r *Renderer) Send(request *http.Request) error {
 	r.proto(request.Proto)
 	r.crlf()
 
+	compression := r.compressionOf(request)
+
 	for headersIter := request.Headers.Iter(); ; {
 		pair, cont := headersIter.Next()
 		if !cont {
 			break
 		}
 
+		if len(compression) > 0 && strcomp.EqualFold(pair.Key, "content-length") {
+			// the length of the compressed body is definitely different
+			continue
+		}
+
 		r.header(pair.Key, pair.Value)
 		r.crlf()
 	}
 
+	for headersIter := r.defaults.Iter(); ; {
+		pair, cont := headersIter.Next()
+		if !cont {
+			break
+		}
+
+		if request.Headers.Has(pair.Key) || isOmitted(request, pair.Key) {
+			continue
+		}
+
+		if len(compression) > 0 && strcomp.EqualFold(pair.Key, "content-length") {
+			continue
+		}
+
+		r.header(pair.Key, pair.Value)
+		r.crlf()
+	}
+
+	if r.isAuto(request, http.AutoHost, "host") && request.Proto != protocol.HTTP09 {
+		r.header("Host", r.host)
+		r.crlf()
+	}
+
+	if !r.hasHeader(request, "accept-encoding") {
+		if accept := r.codings.Accept(r.preferred...); len(accept) > 0 {
+			r.header("Accept-Encoding", accept)
+			r.crlf()
+		}
+	}
+
+	if len(compression) > 0 {
+		r.header("Content-Encoding", compression)
+		r.crlf()
+	}
+
+	if src := bodySource(request); src != nil {
+		return r.stream(request, src, compression, t)
+	}
+
+	body := request.Body
+	if len(compression) > 0 {
+		var err error
+		if body, err = r.encode(body, compression); err != nil {
+			return err
+		}
+	}
+
+	// Content-Length of the caller is dropped for compressed bodies, so the computed one
+	// must be written regardless of it, unless disabled explicitly
+	if request.NoAuto&http.AutoContentLength == 0 && (len(compression) > 0 ||
+		(!r.hasHeader(request, "content-length") && mayHaveBody(request, body))) {
+		r.header("Content-Length", strconv.Itoa(len(body)))
+		r.crlf()
+	}
+
 	r.crlf()
+	r.buff = append(r.buff, body...)
 
-	if request.File != nil {
-		return r.file(request.File)
+	if err := r.client.Write(r.buff); err != nil {
+		return err
+	}
+
+	wroteHeaders(t)
+
+	return nil
+}
+
+// stream renders the body, read from the src. If its size is known, Content-Length is
+// set and the body is just copied. Otherwise, chunked transfer encoding is used. In both
+// cases, the data is sent by pieces no longer than the chunk size. Files of known size
+// are sent without copying at all, if the connection supports it.
+//
+// As the size of the encoded stream is unknown until it's completely read, compressed
+// bodies are always sent using chunked transfer encoding
+func (r *Renderer) stream(
+	request *http.Request, src io.Reader, compression coding.Token, t *trace.ClientTrace,
+) error {
+	size := int64(-1)
+	if len(compression) == 0 {
+		size = length.Of(src)
 	}
 
-	r.buff = append(r.buff, request.Body...)
+	var chunked bool
 
-	fmt.Println("sending:", strconv.Quote(string(r.buff)))
+	switch {
+	case size >= 0:
+		if r.isAuto(request, http.AutoContentLength, "content-length") {
+			r.header("Content-Length", strconv.FormatInt(size, 10))
+			r.crlf()
+		}
+	case r.hasHeader(request, "content-length") && len(compression) == 0:
+		// Content-Length is set by the caller, so it's their responsibility for it to match
+	case r.hasHeader(request, "transfer-encoding"):
+		// the body is framed by the caller, unless chunked is the last coding applied
+		chunked = strcomp.EqualFold(r.lastTransferCoding(request), "chunked")
+	case request.NoAuto&http.AutoTransferEncoding == 0:
+		chunked = true
+		r.header("Transfer-Encoding", "chunked")
+		r.crlf()
+	}
+
+	r.crlf()
+
+	if err := r.client.Write(r.buff); err != nil {
+		return err
+	}
+
+	wroteHeaders(t)
+
+	var dst io.Writer = clientWriter{r.client}
+	if chunked {
+		dst = &r.chunked
+	}
+
+	var err error
+
+	switch {
+	case len(compression) > 0:
+		err = r.encodeStream(dst, src, compression)
+	case size >= 0:
+		err = r.copyExactly(src, size)
+	default:
+		_, err = io.CopyBuffer(dst, onlyReader{src}, r.getCopyBuff())
+	}
+
+	if err != nil || !chunked {
+		return err
+	}
 
-	return r.client.Write(r.buff)
+	return r.chunked.Close()
 }
 
-func (r *Renderer) file(fd *os.File) error {
-	// TODO: implement chunked streaming for files with size>N, where N tends to be more than 1mb
-	content, err := io.ReadAll(fd)
+// copyExactly sends exactly n bytes from the src
+func (r *Renderer) copyExactly(src io.Reader, n int64) error {
+	if file, ok := src.(*os.File); ok {
+		_, err := r.client.SendFile(file, n)
+		if err != tcp.ErrZeroCopyUnsupported {
+			return err
+		}
+	}
+
+	copied, err := io.CopyBuffer(clientWriter{r.client}, io.LimitReader(src, n), r.getCopyBuff())
+	if err == nil && copied < n {
+		// otherwise, the server would wait for the rest of the body forever
+		err = io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// encode returns the body, encoded by the coding. The returned slice is valid until
+// the next call
+func (r *Renderer) encode(body []byte, token coding.Token) ([]byte, error) {
+	r.encodeBuff.Reset()
+	encoder, err := r.codings.NewWriter(token, &r.encodeBuff)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = encoder.Write(body); err != nil {
+		return nil, err
+	}
+
+	if err = encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	return r.encodeBuff.Bytes(), nil
+}
+
+func (r *Renderer) encodeStream(dst io.Writer, src io.Reader, token coding.Token) error {
+	encoder, err := r.codings.NewWriter(token, dst)
 	if err != nil {
 		return err
 	}
 
-	r.buff = append(r.buff, content...)
+	if _, err = io.CopyBuffer(encoder, onlyReader{src}, r.getCopyBuff()); err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
+
+// isAuto reports, whether the header must be set automatically. This is so, if the
+// user didn't set it explicitly neither disabled it
+func (r *Renderer) isAuto(request *http.Request, auto http.AutoHeader, key string) bool {
+	return request.NoAuto&auto == 0 && !r.hasHeader(request, key)
+}
+
+// hasHeader reports, whether the header is rendered, either from the request itself
+// or from the default headers
+func (r *Renderer) hasHeader(request *http.Request, key string) bool {
+	return request.Headers.Has(key) || (r.defaults.Has(key) && !isOmitted(request, key))
+}
+
+// lastTransferCoding returns the last coding, listed in the Transfer-Encoding of either
+// the request itself or the default headers
+func (r *Renderer) lastTransferCoding(request *http.Request) string {
+	values := request.Headers.Values("transfer-encoding")
+	if len(values) == 0 && !isOmitted(request, "transfer-encoding") {
+		values = r.defaults.Values("transfer-encoding")
+	}
+
+	if len(values) == 0 {
+		return ""
+	}
+
+	last := values[len(values)-1]
+	if comma := strings.LastIndexByte(last, ','); comma != -1 {
+		last = last[comma+1:]
+	}
 
-	return r.client.Write(r.buff)
+	return strings.TrimSpace(last)
+}
+
+// compressionOf returns the coding, the request body must be compressed with. Empty
+// token means the body must be sent as it is
+func (r *Renderer) compressionOf(request *http.Request) coding.Token {
+	token := request.Compression
+	if len(token) == 0 {
+		token = r.compression
+	}
+
+	switch {
+	case strcomp.EqualFold(token, "identity"),
+		bodySource(request) == nil && len(request.Body) == 0,
+		// the body is already encoded by the caller
+		r.hasHeader(request, "content-encoding"):
+		return ""
+	}
+
+	return token
+}
+
+func (r *Renderer) getCopyBuff() []byte {
+	if r.copyBuff == nil {
+		size := r.settings.ChunkSize
+		if size <= 0 {
+			size = settings.Default().Request.ChunkSize
+		}
+
+		r.copyBuff = make([]byte, size)
+	}
+
+	return r.copyBuff
 }
 
 func (r *Renderer) method(m method.Method) {
@@ -91,3 +368,58 @@ func (r *Renderer) header(key, value string) {
 	r.buff = append(r.buff, ':', ' ')
 	r.buff = append(r.buff, value...)
 }
+
+// wroteHeaders reports to the trace, if any, that the headers are written
+func wroteHeaders(t *trace.ClientTrace) {
+	if t != nil && t.WroteHeaders != nil {
+		t.WroteHeaders()
+	}
+}
+
+// mayHaveBody reports, whether Content-Length must be set for the body. Methods,
+// that usually carry a body, always get one, even if the body is empty, as otherwise
+// some servers respond with 411 Length Required
+func mayHaveBody(request *http.Request, body []byte) bool {
+	switch request.Method {
+	case method.POST, method.PUT, method.PATCH:
+		return true
+	default:
+		return len(body) > 0
+	}
+}
+
+// isOmitted reports, whether the default header must not be rendered for the request
+func isOmitted(request *http.Request, key string) bool {
+	for _, omitted := range request.Omit {
+		if strcomp.EqualFold(omitted, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bodySource returns a reader, the request body must be streamed from. Nil means the
+// body is passed as bytes
+func bodySource(request *http.Request) io.Reader {
+	if request.File != nil {
+		return request.File
+	}
+
+	return request.Reader
+}
+
+// onlyReader hides all the other methods of the reader. This prevents io.CopyBuffer
+// from using io.WriterTo, which would ignore the buffer, so the chunk size
+type onlyReader struct {
+	io.Reader
+}
+
+// clientWriter adapts tcp.Client to the io.Writer
+type clientWriter struct {
+	client tcp.Client
+}
+
+func (c clientWriter) Write(p []byte) (n int, err error) {
+	return len(p), c.client.Write(p)
+}
@@ -0,0 +1,323 @@
+package http1
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/indigo-web/client/http"
+	"github.com/indigo-web/client/http/coding"
+	"github.com/indigo-web/client/http/headers"
+	"github.com/indigo-web/client/http/method"
+	"github.com/indigo-web/client/internal/tcp"
+	"github.com/indigo-web/client/metrics"
+	"github.com/indigo-web/client/settings"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	nethttp "net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type writeRecorder struct {
+	data     []byte
+	maxWrite int
+}
+
+func (w *writeRecorder) Read() ([]byte, error)              { return nil, io.EOF }
+func (w *writeRecorder) Unread([]byte)                      {}
+func (w *writeRecorder) Remote() net.Addr                   { return nil }
+func (w *writeRecorder) Reset(net.Conn)                     {}
+func (w *writeRecorder) SetMetrics(metrics.Metrics, string) {}
+func (w *writeRecorder) Close() error                       { return nil }
+
+func (w *writeRecorder) SendFile(*os.File, int64) (int64, error) {
+	return 0, tcp.ErrZeroCopyUnsupported
+}
+
+func (w *writeRecorder) Write(b []byte) error {
+	w.data = append(w.data, b...)
+	w.maxWrite = max(w.maxWrite, len(b))
+	return nil
+}
+
+// sendFileRecorder counts data, sent by the SendFile of the wrapped client
+type sendFileRecorder struct {
+	tcp.Client
+	calls int
+	sent  int64
+}
+
+func (s *sendFileRecorder) SendFile(file *os.File, n int64) (int64, error) {
+	s.calls++
+	sent, err := s.Client.SendFile(file, n)
+	s.sent += sent
+	return sent, err
+}
+
+// parseRequest parses the rendered request using the standard library in order to
+// get an independent opinion
+func parseRequest(t *testing.T, data []byte) (*nethttp.Request, []byte) {
+	request, err := nethttp.ReadRequest(bufio.NewReader(bytes.NewReader(data)))
+	require.NoError(t, err)
+	body, err := io.ReadAll(request.Body)
+	require.NoError(t, err)
+
+	return request, body
+}
+
+func newRequest() *http.Request {
+	return http.NewRequest(headers.NewHeaders()).WithMethod(method.POST).WithPath("/")
+}
+
+func TestRenderer(t *testing.T) {
+	const sample = "Hello, world! Lorem ipsum dolor sit amet"
+	codings := coding.NewDefaultManager()
+
+	t.Run("auto headers", func(t *testing.T) {
+		client := new(writeRecorder)
+		renderer := NewRenderer(client, "localhost", headers.NewHeaders(), nil, codings, settings.Default().Request)
+		require.NoError(t, renderer.Send(newRequest().WithBody(sample), nil))
+		req, body := parseRequest(t, client.data)
+		require.Equal(t, "localhost", req.Host)
+		require.Equal(t, int64(len(sample)), req.ContentLength)
+		require.Equal(t, sample, string(body))
+
+		client.data = client.data[:0]
+		require.NoError(t, renderer.Send(newRequest(), nil))
+		req, _ = parseRequest(t, client.data)
+		require.Equal(t, []string{"0"}, req.Header.Values("Content-Length"))
+
+		client.data = client.data[:0]
+		require.NoError(t, renderer.Send(newRequest().WithMethod(method.GET).WithHeader("Host", "example.com"), nil))
+		req, _ = parseRequest(t, client.data)
+		require.Equal(t, "example.com", req.Host)
+		require.Empty(t, req.Header.Values("Content-Length"))
+	})
+
+	t.Run("disabled auto headers", func(t *testing.T) {
+		client := new(writeRecorder)
+		renderer := NewRenderer(client, "localhost", headers.NewHeaders(), nil, codings, settings.Default().Request)
+		request := newRequest().
+			WithBody(sample).
+			WithoutAuto(http.AutoHost | http.AutoContentLength)
+		require.NoError(t, renderer.Send(request, nil))
+		require.NotContains(t, string(client.data), "Host")
+		require.NotContains(t, string(client.data), "Content-Length")
+
+		client.data = client.data[:0]
+		request = newRequest().
+			WithBodyFrom(io.MultiReader(strings.NewReader(sample))).
+			WithoutAuto(http.AutoTransferEncoding)
+		require.NoError(t, renderer.Send(request, nil))
+		require.NotContains(t, string(client.data), "Transfer-Encoding")
+		require.True(t, strings.HasSuffix(string(client.data), "\r\n\r\n"+sample))
+	})
+
+	t.Run("disabled content length of compressed body", func(t *testing.T) {
+		client := new(writeRecorder)
+		renderer := NewRenderer(client, "localhost", headers.NewHeaders(), nil, codings, settings.Default().Request)
+		request := newRequest().
+			WithBody(sample).
+			WithCompression("gzip").
+			WithoutAuto(http.AutoContentLength)
+		require.NoError(t, renderer.Send(request, nil))
+		require.Contains(t, string(client.data), "Content-Encoding: gzip")
+		require.NotContains(t, string(client.data), "Content-Length")
+	})
+
+	t.Run("custom transfer encoding", func(t *testing.T) {
+		client := new(writeRecorder)
+		renderer := NewRenderer(client, "localhost", headers.NewHeaders(), nil, codings, settings.Default().Request)
+		request := newRequest().
+			WithBodyFrom(io.MultiReader(strings.NewReader(sample))).
+			WithHeader("Transfer-Encoding", "gzip")
+		require.NoError(t, renderer.Send(request, nil))
+		require.True(t, strings.HasSuffix(string(client.data), "\r\n\r\n"+sample))
+
+		client.data = client.data[:0]
+		request = newRequest().
+			WithBodyFrom(io.MultiReader(strings.NewReader(sample))).
+			WithHeader("Transfer-Encoding", "gzip, chunked")
+		require.NoError(t, renderer.Send(request, nil))
+		require.True(t, strings.HasSuffix(string(client.data), sample+"\r\n0\r\n\r\n"))
+	})
+
+	t.Run("default headers", func(t *testing.T) {
+		client := new(writeRecorder)
+		defaults := headers.NewHeaders()
+		defaults.Add("User-Agent", "indigo-client")
+		defaults.Add("Authorization", "Bearer token")
+		defaults.Add("Accept-Encoding", "gzip")
+		renderer := NewRenderer(client, "localhost", defaults, nil, codings, settings.Default().Request)
+
+		require.NoError(t, renderer.Send(newRequest(), nil))
+		req, _ := parseRequest(t, client.data)
+		require.Equal(t, "indigo-client", req.Header.Get("User-Agent"))
+		require.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+		require.Equal(t, []string{"gzip"}, req.Header.Values("Accept-Encoding"))
+
+		client.data = client.data[:0]
+		request := newRequest().
+			WithHeader("user-agent", "custom").
+			WithoutHeader("Authorization")
+		require.NoError(t, renderer.Send(request, nil))
+		req, _ = parseRequest(t, client.data)
+		require.Equal(t, []string{"custom"}, req.Header.Values("User-Agent"))
+		require.Empty(t, req.Header.Values("Authorization"))
+	})
+
+	t.Run("accept-encoding", func(t *testing.T) {
+		client := new(writeRecorder)
+		renderer := NewRenderer(client, "localhost", headers.NewHeaders(), nil, codings, settings.Default().Request)
+		require.NoError(t, renderer.Send(newRequest(), nil))
+		req, _ := parseRequest(t, client.data)
+		require.Equal(t, "zstd, br;q=0.9, gzip;q=0.8, deflate;q=0.7", req.Header.Get("Accept-Encoding"))
+
+		client.data = client.data[:0]
+		renderer.SetPreferredEncodings([]coding.Token{"gzip", "unknown"})
+		require.NoError(t, renderer.Send(newRequest(), nil))
+		req, _ = parseRequest(t, client.data)
+		require.Equal(t, "gzip, zstd;q=0.9, br;q=0.8, deflate;q=0.7", req.Header.Get("Accept-Encoding"))
+
+		client.data = client.data[:0]
+		require.NoError(t, renderer.Send(newRequest().WithHeader("Accept-Encoding", "identity"), nil))
+		req, _ = parseRequest(t, client.data)
+		require.Equal(t, []string{"identity"}, req.Header.Values("Accept-Encoding"))
+	})
+
+	t.Run("streamed file", func(t *testing.T) {
+		content := bytes.Repeat([]byte(sample), 1000)
+		filename := filepath.Join(t.TempDir(), "sample.txt")
+		require.NoError(t, os.WriteFile(filename, content, 0644))
+
+		client := new(writeRecorder)
+		renderer := NewRenderer(client, "localhost", headers.NewHeaders(), nil, codings, settings.Request{ChunkSize: 1024})
+		request := newRequest().WithFile(filename)
+		require.NoError(t, request.Error())
+		require.NoError(t, renderer.Send(request, nil))
+
+		req, body := parseRequest(t, client.data)
+		require.Equal(t, int64(len(content)), req.ContentLength)
+		require.Equal(t, content, body)
+		require.LessOrEqual(t, client.maxWrite, 1024)
+	})
+
+	t.Run("zero-copy file", func(t *testing.T) {
+		content := bytes.Repeat([]byte(sample), 1000)
+		filename := filepath.Join(t.TempDir(), "sample.txt")
+		require.NoError(t, os.WriteFile(filename, content, 0644))
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer listener.Close()
+
+		received := make(chan []byte, 1)
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				received <- nil
+				return
+			}
+
+			defer conn.Close()
+			data, _ := io.ReadAll(conn)
+			received <- data
+		}()
+
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		require.NoError(t, err)
+		client := &sendFileRecorder{Client: tcp.NewClient(conn, time.Second, time.Second, nil)}
+		renderer := NewRenderer(client, "localhost", headers.NewHeaders(), nil, codings, settings.Default().Request)
+		request := newRequest().WithFile(filename)
+		require.NoError(t, request.Error())
+		defer request.Close()
+		require.NoError(t, renderer.Send(request, nil))
+		require.NoError(t, conn.Close())
+
+		req, body := parseRequest(t, <-received)
+		require.Equal(t, int64(len(content)), req.ContentLength)
+		require.Equal(t, content, body)
+		require.Equal(t, 1, client.calls)
+		require.Equal(t, int64(len(content)), client.sent)
+	})
+
+	t.Run("streamed reader of unknown size", func(t *testing.T) {
+		content := bytes.Repeat([]byte(sample), 1000)
+
+		client := new(writeRecorder)
+		renderer := NewRenderer(client, "localhost", headers.NewHeaders(), nil, codings, settings.Request{ChunkSize: 1024})
+		request := newRequest().WithBodyFrom(io.MultiReader(bytes.NewReader(content)))
+		require.NoError(t, renderer.Send(request, nil))
+
+		req, body := parseRequest(t, client.data)
+		require.Equal(t, []string{"chunked"}, req.TransferEncoding)
+		require.Equal(t, content, body)
+		// chunk length and CRLFs are sent alongside with the chunk itself
+		require.LessOrEqual(t, client.maxWrite, 1024+len("400\r\n\r\n"))
+	})
+
+	t.Run("zero chunk size", func(t *testing.T) {
+		client := new(writeRecorder)
+		renderer := NewRenderer(client, "localhost", headers.NewHeaders(), nil, codings, settings.Request{})
+		request := newRequest().WithBodyFrom(io.MultiReader(strings.NewReader(sample)))
+		require.NoError(t, renderer.Send(request, nil))
+
+		_, body := parseRequest(t, client.data)
+		require.Equal(t, sample, string(body))
+	})
+
+	t.Run("compressed body", func(t *testing.T) {
+		client := new(writeRecorder)
+		renderer := NewRenderer(client, "localhost", headers.NewHeaders(), nil, codings, settings.Default().Request)
+		request := newRequest().WithBody(sample).WithCompression("gzip")
+		require.NoError(t, renderer.Send(request, nil))
+
+		req, body := parseRequest(t, client.data)
+		require.Equal(t, "gzip", req.Header.Get("Content-Encoding"))
+		require.Equal(t, int64(len(body)), req.ContentLength)
+		decoded, err := codings.Decode("gzip", body)
+		require.NoError(t, err)
+		require.Equal(t, sample, string(decoded))
+	})
+
+	t.Run("compressed body with preset length", func(t *testing.T) {
+		client := new(writeRecorder)
+		renderer := NewRenderer(client, "localhost", headers.NewHeaders(), nil, codings, settings.Default().Request)
+		request := newRequest().
+			WithBody(sample).
+			WithHeader("Content-Length", strconv.Itoa(len(sample))).
+			WithCompression("gzip")
+		require.NoError(t, renderer.Send(request, nil))
+
+		req, body := parseRequest(t, client.data)
+		require.Equal(t, "gzip", req.Header.Get("Content-Encoding"))
+		require.Equal(t, int64(len(body)), req.ContentLength)
+		require.NotEqual(t, int64(len(sample)), req.ContentLength)
+		decoded, err := codings.Decode("gzip", body)
+		require.NoError(t, err)
+		require.Equal(t, sample, string(decoded))
+	})
+
+	t.Run("compressed file", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "sample.txt")
+		require.NoError(t, os.WriteFile(filename, []byte(sample), 0644))
+
+		client := new(writeRecorder)
+		renderer := NewRenderer(client, "localhost", headers.NewHeaders(), nil, codings, settings.Default().Request)
+		renderer.SetCompression("zstd")
+		request := newRequest().WithFile(filename)
+		require.NoError(t, request.Error())
+		require.NoError(t, renderer.Send(request, nil))
+
+		req, body := parseRequest(t, client.data)
+		require.Equal(t, "zstd", req.Header.Get("Content-Encoding"))
+		require.Equal(t, []string{"chunked"}, req.TransferEncoding)
+		decoded, err := codings.Decode("zstd", body)
+		require.NoError(t, err)
+		require.Equal(t, sample, string(decoded))
+	})
+}
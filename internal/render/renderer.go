@@ -3,25 +3,43 @@ package render
 import (
 	"fmt"
 	"github.com/indigo-web/client/http"
+	"github.com/indigo-web/client/http/coding"
+	"github.com/indigo-web/client/http/headers"
 	"github.com/indigo-web/client/http/protocol"
 	"github.com/indigo-web/client/internal/render/http1"
 	"github.com/indigo-web/client/internal/tcp"
+	"github.com/indigo-web/client/settings"
+	"github.com/indigo-web/client/trace"
 )
 
 type Renderer struct {
 	http1 *http1.Renderer
 }
 
-func NewRenderer(client tcp.Client, buff []byte) Renderer {
+func NewRenderer(
+	client tcp.Client, host string, defaults *headers.Headers, buff []byte,
+	codings coding.Manager, s settings.Request,
+) Renderer {
 	return Renderer{
-		http1: http1.NewRenderer(client, buff),
+		http1: http1.NewRenderer(client, host, defaults, buff, codings, s),
 	}
 }
 
-func (r Renderer) Send(request *http.Request) error {
+// SetCompression sets the coding, request bodies are compressed with by default
+func (r Renderer) SetCompression(token coding.Token) {
+	r.http1.SetCompression(token)
+}
+
+// SetPreferredEncodings sets codings, advertised with higher priority in Accept-Encoding
+func (r Renderer) SetPreferredEncodings(tokens []coding.Token) {
+	r.http1.SetPreferredEncodings(tokens)
+}
+
+// Send renders the request and writes it. The trace may be nil
+func (r Renderer) Send(request *http.Request, t *trace.ClientTrace) error {
 	switch request.Proto {
 	case protocol.HTTP09, protocol.HTTP10, protocol.HTTP11:
-		return r.http1.Send(request)
+		return r.http1.Send(request, t)
 	}
 
 	return fmt.Errorf("unsupported protocol: %s", request.Proto)
@@ -1,16 +1,35 @@
 package tcp
 
 import (
+	"errors"
+	"github.com/indigo-web/client/metrics"
 	"github.com/indigo-web/utils/unreader"
+	"io"
 	"net"
+	"os"
 	"time"
 )
 
+// ErrZeroCopyUnsupported is returned by SendFile, if the connection doesn't support
+// sending files bypassing userspace buffers, e.g. TLS
+var ErrZeroCopyUnsupported = errors.New("connection doesn't support zero-copy")
+
+// zeroCopySegment is the maximal number of bytes, sent by a single sendfile call. This
+// is required in order to apply the write timeout to each segment instead of the whole
+// file, which may be sent for a long time
+const zeroCopySegment = 4 * 1024 * 1024
+
 type Client interface {
 	Read() ([]byte, error)
 	Unread([]byte)
 	Write([]byte) error
+	SendFile(file *os.File, n int64) (int64, error)
 	Remote() net.Addr
+	// Reset replaces the connection with a new one, discarding all the pending data
+	Reset(conn net.Conn)
+	// SetMetrics sets the metrics, all the bytes read and written are reported to
+	// on behalf of the host. Nil disables it
+	SetMetrics(m metrics.Metrics, host string)
 	Close() error
 }
 
@@ -19,6 +38,8 @@ type client struct {
 	unreader           *unreader.Unreader
 	buff               []byte
 	rTimeout, wTimeout time.Duration
+	metrics            metrics.Metrics
+	host               string
 }
 
 func NewClient(conn net.Conn, rTimeout, wTimeout time.Duration, buff []byte) Client {
@@ -38,6 +59,9 @@ func (c *client) Read() ([]byte, error) {
 		}
 
 		n, err := c.conn.Read(c.buff)
+		if n > 0 && c.metrics != nil {
+			c.metrics.BytesRead(c.host, n)
+		}
 
 		return c.buff[:n], err
 	})
@@ -52,15 +76,60 @@ func (c *client) Write(b []byte) error {
 		return err
 	}
 
-	_, err := c.conn.Write(b)
+	n, err := c.conn.Write(b)
+	if n > 0 && c.metrics != nil {
+		c.metrics.BytesWritten(c.host, n)
+	}
 
 	return err
 }
 
+// SendFile sends n bytes of the file directly from its descriptor (via sendfile or
+// splice). If the connection doesn't support it, ErrZeroCopyUnsupported is returned
+// and nothing is sent
+func (c *client) SendFile(file *os.File, n int64) (sent int64, err error) {
+	conn, ok := c.conn.(io.ReaderFrom)
+	if !ok {
+		return 0, ErrZeroCopyUnsupported
+	}
+
+	for sent < n {
+		if err = c.conn.SetWriteDeadline(time.Now().Add(c.wTimeout)); err != nil {
+			return sent, err
+		}
+
+		segment, err := conn.ReadFrom(io.LimitReader(file, min(n-sent, zeroCopySegment)))
+		sent += segment
+		if segment > 0 && c.metrics != nil {
+			c.metrics.BytesWritten(c.host, int(segment))
+		}
+
+		if err != nil {
+			return sent, err
+		}
+
+		if segment == 0 {
+			return sent, io.ErrUnexpectedEOF
+		}
+	}
+
+	return sent, nil
+}
+
 func (c *client) Remote() net.Addr {
 	return c.conn.RemoteAddr()
 }
 
+func (c *client) Reset(conn net.Conn) {
+	c.conn = conn
+	c.unreader.Reset()
+}
+
+func (c *client) SetMetrics(m metrics.Metrics, host string) {
+	c.metrics = m
+	c.host = host
+}
+
 func (c *client) Close() error {
 	return c.conn.Close()
 }
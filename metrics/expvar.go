@@ -0,0 +1,173 @@
+package metrics
+
+import (
+	"expvar"
+	"github.com/indigo-web/client/http/status"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Expvar publishes the metrics via the expvar package, so they're served at
+// /debug/vars. Each host has its own map of variables:
+//
+//	requests         number of requests by the status class, and failed ones as "error"
+//	first_byte_ms    histogram of the time to the first byte
+//	total_ms         histogram of the time to the end of the body
+//	bytes_written    number of bytes written to the connections
+//	bytes_read       number of bytes read from the connections
+//	reconnects       number of reconnects
+//	pool_active      number of sessions of the pool in use
+//	pool_idle        number of idle sessions of the pool
+type Expvar struct {
+	mu    sync.Mutex
+	root  *expvar.Map
+	hosts map[string]*hostVars
+}
+
+// NewExpvar publishes the metrics under the name. As expvar.Publish does, it panics,
+// if the name is already in use
+func NewExpvar(name string) *Expvar {
+	return &Expvar{
+		root:  expvar.NewMap(name),
+		hosts: make(map[string]*hostVars),
+	}
+}
+
+func (e *Expvar) Response(host string, code status.Code, firstByte time.Duration) {
+	vars := e.host(host)
+	vars.requests.Add(Class(code), 1)
+	vars.firstByte.Observe(firstByte)
+}
+
+func (e *Expvar) Failure(host string, _ error) {
+	e.host(host).requests.Add("error", 1)
+}
+
+func (e *Expvar) Completed(host string, total time.Duration) {
+	e.host(host).total.Observe(total)
+}
+
+func (e *Expvar) BytesWritten(host string, n int) {
+	e.host(host).bytesWritten.Add(int64(n))
+}
+
+func (e *Expvar) BytesRead(host string, n int) {
+	e.host(host).bytesRead.Add(int64(n))
+}
+
+func (e *Expvar) Reconnect(host string) {
+	e.host(host).reconnects.Add(1)
+}
+
+func (e *Expvar) PoolUtilization(host string, active, idle int) {
+	vars := e.host(host)
+	vars.poolActive.Set(int64(active))
+	vars.poolIdle.Set(int64(idle))
+}
+
+func (e *Expvar) host(host string) *hostVars {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if vars, found := e.hosts[host]; found {
+		return vars
+	}
+
+	vars := &hostVars{
+		requests:  new(expvar.Map).Init(),
+		firstByte: NewHistogram(),
+		total:     NewHistogram(),
+	}
+
+	m := new(expvar.Map).Init()
+	m.Set("requests", vars.requests)
+	m.Set("first_byte_ms", vars.firstByte)
+	m.Set("total_ms", vars.total)
+	m.Set("bytes_written", &vars.bytesWritten)
+	m.Set("bytes_read", &vars.bytesRead)
+	m.Set("reconnects", &vars.reconnects)
+	m.Set("pool_active", &vars.poolActive)
+	m.Set("pool_idle", &vars.poolIdle)
+	e.root.Set(host, m)
+	e.hosts[host] = vars
+
+	return vars
+}
+
+type hostVars struct {
+	requests     *expvar.Map
+	firstByte    *Histogram
+	total        *Histogram
+	bytesWritten expvar.Int
+	bytesRead    expvar.Int
+	reconnects   expvar.Int
+	poolActive   expvar.Int
+	poolIdle     expvar.Int
+}
+
+// histogramBounds are upper bounds of histogram buckets in milliseconds. Observations
+// above the last one fall into the +Inf bucket
+var histogramBounds = [...]int64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// Histogram counts durations by buckets with fixed bounds. It implements expvar.Var,
+// rendering as a JSON object with count, sum of milliseconds and cumulative buckets,
+// keyed by their upper bounds
+type Histogram struct {
+	count   atomic.Int64
+	sum     atomic.Int64
+	buckets [len(histogramBounds) + 1]atomic.Int64
+}
+
+func NewHistogram() *Histogram {
+	return new(Histogram)
+}
+
+// Observe records the duration
+func (h *Histogram) Observe(d time.Duration) {
+	ms := d.Milliseconds()
+	i := 0
+	for i < len(histogramBounds) && ms > histogramBounds[i] {
+		i++
+	}
+
+	h.buckets[i].Add(1)
+	h.count.Add(1)
+	h.sum.Add(ms)
+}
+
+// Count returns the number of observations
+func (h *Histogram) Count() int64 {
+	return h.count.Load()
+}
+
+func (h *Histogram) String() string {
+	var b strings.Builder
+	b.WriteString(`{"count":`)
+	b.WriteString(strconv.FormatInt(h.count.Load(), 10))
+	b.WriteString(`,"sum":`)
+	b.WriteString(strconv.FormatInt(h.sum.Load(), 10))
+	b.WriteString(`,"buckets":{`)
+
+	var cumulative int64
+	for i := range h.buckets {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		bound := "+Inf"
+		if i < len(histogramBounds) {
+			bound = strconv.FormatInt(histogramBounds[i], 10)
+		}
+
+		cumulative += h.buckets[i].Load()
+		b.WriteString(`"` + bound + `":`)
+		b.WriteString(strconv.FormatInt(cumulative, 10))
+	}
+
+	b.WriteString("}}")
+
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram()
+	h.Observe(500 * time.Microsecond)
+	h.Observe(3 * time.Millisecond)
+	h.Observe(time.Minute)
+
+	var parsed struct {
+		Count   int64            `json:"count"`
+		Sum     int64            `json:"sum"`
+		Buckets map[string]int64 `json:"buckets"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(h.String()), &parsed))
+	require.Equal(t, int64(3), parsed.Count)
+	require.Equal(t, int64(60003), parsed.Sum)
+	require.Equal(t, int64(1), parsed.Buckets["1"])
+	require.Equal(t, int64(1), parsed.Buckets["2"])
+	require.Equal(t, int64(2), parsed.Buckets["5"])
+	require.Equal(t, int64(2), parsed.Buckets["10000"])
+	require.Equal(t, int64(3), parsed.Buckets["+Inf"])
+}
+
+func TestExpvar(t *testing.T) {
+	m := NewExpvar("test_expvar")
+	m.Response("example.com", 200, 10*time.Millisecond)
+	m.Response("example.com", 204, 10*time.Millisecond)
+	m.Response("example.com", 503, 10*time.Millisecond)
+	m.Failure("example.com", errors.New("connection reset"))
+	m.Completed("example.com", 20*time.Millisecond)
+	m.BytesWritten("example.com", 100)
+	m.BytesRead("example.com", 50)
+	m.BytesRead("example.com", 50)
+	m.Reconnect("example.com")
+	m.PoolUtilization("example.com", 2, 1)
+	m.BytesRead("example.org", 1)
+
+	var parsed map[string]struct {
+		Requests     map[string]int64      `json:"requests"`
+		FirstByte    struct{ Count int64 } `json:"first_byte_ms"`
+		Total        struct{ Count int64 } `json:"total_ms"`
+		BytesWritten int64                 `json:"bytes_written"`
+		BytesRead    int64                 `json:"bytes_read"`
+		Reconnects   int64                 `json:"reconnects"`
+		PoolActive   int64                 `json:"pool_active"`
+		PoolIdle     int64                 `json:"pool_idle"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(expvar.Get("test_expvar").String()), &parsed))
+	require.Len(t, parsed, 2)
+
+	host := parsed["example.com"]
+	require.Equal(t, map[string]int64{"2xx": 2, "5xx": 1, "error": 1}, host.Requests)
+	require.Equal(t, int64(3), host.FirstByte.Count)
+	require.Equal(t, int64(1), host.Total.Count)
+	require.Equal(t, int64(100), host.BytesWritten)
+	require.Equal(t, int64(100), host.BytesRead)
+	require.Equal(t, int64(1), host.Reconnects)
+	require.Equal(t, int64(2), host.PoolActive)
+	require.Equal(t, int64(1), host.PoolIdle)
+	require.Equal(t, int64(1), parsed["example.org"].BytesRead)
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"github.com/indigo-web/client/http/status"
+	"time"
+)
+
+// Metrics receives measurements from sessions and pools. Hosts are identified the same
+// way, as for the rate limiter. Implementations must be safe for concurrent use and
+// must not block, as they're called synchronously while sending requests
+type Metrics interface {
+	// Response is called once the response headers are received. The firstByte is the
+	// time since the request started being sent till the first byte of the response
+	Response(host string, code status.Code, firstByte time.Duration)
+	// Failure is called, if the request fails before the response is received
+	Failure(host string, err error)
+	// Completed is called once the response body is received completely. The total is
+	// the time since the request started being sent
+	Completed(host string, total time.Duration)
+	// BytesWritten is called on every write to the connection
+	BytesWritten(host string, n int)
+	// BytesRead is called on every read from the connection
+	BytesRead(host string, n int)
+	// Reconnect is called, when the broken connection is replaced by a new one
+	Reconnect(host string)
+	// PoolUtilization is called every time a session of the pool is acquired or
+	// released, with the numbers of sessions of the host, which are in use and idle
+	PoolUtilization(host string, active, idle int)
+}
+
+// Class returns the class of the status code, e.g. 2xx. Codes out of range give "other"
+func Class(code status.Code) string {
+	switch code / 100 {
+	case 1:
+		return "1xx"
+	case 2:
+		return "2xx"
+	case 3:
+		return "3xx"
+	case 4:
+		return "4xx"
+	case 5:
+		return "5xx"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,151 @@
+package client
+
+import (
+	"github.com/indigo-web/client/http/status"
+	"github.com/indigo-web/client/metrics"
+	"github.com/indigo-web/client/settings"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type metricsRecorder struct {
+	mu           sync.Mutex
+	classes      map[string]int
+	failures     int
+	firstBytes   int
+	completed    int
+	written      int
+	read         int
+	reconnects   int
+	active, idle int
+}
+
+func newMetricsRecorder() *metricsRecorder {
+	return &metricsRecorder{classes: make(map[string]int)}
+}
+
+func (m *metricsRecorder) Response(_ string, code status.Code, _ time.Duration) {
+	m.mu.Lock()
+	m.classes[metrics.Class(code)]++
+	m.firstBytes++
+	m.mu.Unlock()
+}
+
+func (m *metricsRecorder) Failure(string, error) {
+	m.mu.Lock()
+	m.failures++
+	m.mu.Unlock()
+}
+
+func (m *metricsRecorder) Completed(string, time.Duration) {
+	m.mu.Lock()
+	m.completed++
+	m.mu.Unlock()
+}
+
+func (m *metricsRecorder) BytesWritten(_ string, n int) {
+	m.mu.Lock()
+	m.written += n
+	m.mu.Unlock()
+}
+
+func (m *metricsRecorder) BytesRead(_ string, n int) {
+	m.mu.Lock()
+	m.read += n
+	m.mu.Unlock()
+}
+
+func (m *metricsRecorder) Reconnect(string) {
+	m.mu.Lock()
+	m.reconnects++
+	m.mu.Unlock()
+}
+
+func (m *metricsRecorder) PoolUtilization(_ string, active, idle int) {
+	m.mu.Lock()
+	m.active, m.idle = active, idle
+	m.mu.Unlock()
+}
+
+func TestMetrics(t *testing.T) {
+	handler := nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(nethttp.StatusNotFound)
+		}
+
+		_, _ = w.Write([]byte("hello"))
+	})
+
+	t.Run("pool", func(t *testing.T) {
+		server := httptest.NewServer(handler)
+		defer server.Close()
+		pool := NewPool(settings.Default(), nil)
+		defer pool.Close()
+		m := newMetricsRecorder()
+		pool.SetMetrics(m)
+
+		resp, err := pool.Get(server.URL + "/")
+		require.NoError(t, err)
+		require.Equal(t, 1, m.active)
+		require.Equal(t, 0, m.idle)
+		require.NoError(t, resp.Body.Close())
+		require.Equal(t, 0, m.active)
+		require.Equal(t, 1, m.idle)
+
+		resp, err = pool.Get(server.URL + "/missing")
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, "hello", string(body))
+		require.NoError(t, resp.Body.Close())
+
+		require.Equal(t, map[string]int{"2xx": 1, "4xx": 1}, m.classes)
+		require.Equal(t, 2, m.firstBytes)
+		require.Equal(t, 2, m.completed)
+		require.Zero(t, m.failures)
+		require.Positive(t, m.written)
+		require.Positive(t, m.read)
+	})
+
+	t.Run("reconnect", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer listener.Close()
+
+		go func() {
+			// the first connection is dropped, so the request must be retried
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			_ = conn.Close()
+			_ = nethttp.Serve(listener, handler)
+		}()
+
+		s := settings.Default()
+		s.Retry = settings.DefaultRetry()
+		s.Retry.BaseDelay = time.Millisecond
+		session, err := NewSessionWithSettings(listener.Addr().String(), s)
+		require.NoError(t, err)
+		defer session.Close()
+		m := newMetricsRecorder()
+		session.SetMetrics(m)
+
+		resp, err := session.Send(session.GET("/"))
+		require.NoError(t, err)
+		require.Equal(t, status.OK, resp.Code)
+		require.NoError(t, resp.Body.Close())
+
+		require.Equal(t, 1, m.failures)
+		require.Equal(t, 1, m.reconnects)
+		require.Equal(t, map[string]int{"2xx": 1}, m.classes)
+		require.Equal(t, 1, m.completed)
+	})
+}
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"github.com/indigo-web/client/http"
+)
+
+// RoundTripper sends the request and returns the response with parsed headers
+type RoundTripper interface {
+	RoundTrip(request *http.Request) (*http.Response, error)
+}
+
+// RoundTripperFunc adapts a function to the RoundTripper
+type RoundTripperFunc func(request *http.Request) (*http.Response, error)
+
+func (r RoundTripperFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return r(request)
+}
+
+// Middleware wraps the next RoundTripper. It may modify the request before passing it
+// further, inspect or modify the response after, or not call the next one at all, e.g.
+// serving the response from a cache
+type Middleware func(next RoundTripper) RoundTripper
+
+// chain wraps the transport into middlewares. The first middleware is the outermost,
+// so it's the first to see the request and the last to see the response
+func chain(transport RoundTripper, middlewares []Middleware) RoundTripper {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		transport = middlewares[i](transport)
+	}
+
+	return transport
+}
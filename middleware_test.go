@@ -0,0 +1,84 @@
+package client
+
+import (
+	"errors"
+	"github.com/indigo-web/client/http"
+	"github.com/indigo-web/client/settings"
+	"github.com/stretchr/testify/require"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(nethttp.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("X-Path", r.URL.Path)
+	}))
+	defer server.Close()
+
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next RoundTripper) RoundTripper {
+			return RoundTripperFunc(func(request *http.Request) (*http.Response, error) {
+				calls = append(calls, name+" request")
+				resp, err := next.RoundTrip(request)
+				calls = append(calls, name+" response")
+				return resp, err
+			})
+		}
+	}
+
+	auth := func(next RoundTripper) RoundTripper {
+		return RoundTripperFunc(func(request *http.Request) (*http.Response, error) {
+			return next.RoundTrip(request.WithHeader("Authorization", "Bearer token"))
+		})
+	}
+
+	t.Run("session", func(t *testing.T) {
+		calls = nil
+		session, err := NewSession(server.Listener.Addr().String())
+		require.NoError(t, err)
+		defer session.Close()
+		session.Use(record("outer"), record("inner"))
+		session.Use(auth)
+
+		resp, err := session.Send(session.GET("/hello"))
+		require.NoError(t, err)
+		require.Equal(t, 200, int(resp.Code))
+		require.Equal(t, "/hello", resp.Headers.Value("x-path"))
+		require.Equal(t, []string{"outer request", "inner request", "inner response", "outer response"}, calls)
+	})
+
+	t.Run("short-circuit", func(t *testing.T) {
+		errBlocked := errors.New("blocked")
+		session, err := NewSession(server.Listener.Addr().String())
+		require.NoError(t, err)
+		defer session.Close()
+		session.Use(func(RoundTripper) RoundTripper {
+			return RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+				return nil, errBlocked
+			})
+		})
+
+		_, err = session.Send(session.GET("/"))
+		require.ErrorIs(t, err, errBlocked)
+	})
+
+	t.Run("pool", func(t *testing.T) {
+		calls = nil
+		pool := NewPool(settings.Default(), nil)
+		defer pool.Close()
+		pool.Use(record("pool"), auth)
+
+		resp, err := pool.Get(server.URL + "/pooled")
+		require.NoError(t, err)
+		require.Equal(t, "/pooled", resp.Headers.Value("x-path"))
+		require.NoError(t, resp.Body.Close())
+		require.Equal(t, []string{"pool request", "pool response"}, calls)
+	})
+}
@@ -0,0 +1,373 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"github.com/indigo-web/client/http"
+	"github.com/indigo-web/client/http/headers"
+	"github.com/indigo-web/client/http/method"
+	"github.com/indigo-web/client/http/protocol"
+	"github.com/indigo-web/client/metrics"
+	"github.com/indigo-web/client/settings"
+	"github.com/indigo-web/client/trace"
+	"github.com/indigo-web/utils/strcomp"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// maxIdlePerHost is the maximal number of idle sessions, kept per each origin
+const maxIdlePerHost = 8
+
+var ErrUnsupportedScheme = errors.New("only http and https schemes are supported")
+
+// Pool keeps idle sessions and reuses them for requests to the same origin. It's safe
+// for concurrent use
+type Pool struct {
+	mu          sync.Mutex
+	idle        map[origin][]*Session
+	settings    settings.Settings
+	tlsConfig   *tls.Config
+	limiter     *RateLimiter
+	breaker     *CircuitBreaker
+	middlewares []Middleware
+	metrics     metrics.Metrics
+	// active is the number of sessions per origin, which are currently in use
+	active map[origin]int
+}
+
+// NewPool returns a new pool. If tlsConfig is nil, the default one is used
+func NewPool(s settings.Settings, tlsConfig *tls.Config) *Pool {
+	return &Pool{
+		idle:      make(map[origin][]*Session),
+		active:    make(map[origin]int),
+		settings:  s,
+		tlsConfig: tlsConfig,
+	}
+}
+
+var defaultPool = NewPool(settings.Default(), nil)
+
+// Get sends a GET request to the URL using the default pool
+func Get(rawURL string) (*http.Response, error) {
+	return defaultPool.Get(rawURL)
+}
+
+// Head sends a HEAD request to the URL using the default pool
+func Head(rawURL string) (*http.Response, error) {
+	return defaultPool.Head(rawURL)
+}
+
+// Post sends a POST request with the body to the URL using the default pool
+func Post(rawURL, contentType string, body []byte) (*http.Response, error) {
+	return defaultPool.Post(rawURL, contentType, body)
+}
+
+// Delete sends a DELETE request to the URL using the default pool
+func Delete(rawURL string) (*http.Response, error) {
+	return defaultPool.Delete(rawURL)
+}
+
+// Send sends the request to the URL using the default pool
+func Send(rawURL string, request *http.Request) (*http.Response, error) {
+	return defaultPool.Send(rawURL, request)
+}
+
+func (p *Pool) Get(rawURL string) (*http.Response, error) {
+	return p.Send(rawURL, newRequest().WithMethod(method.GET))
+}
+
+func (p *Pool) Head(rawURL string) (*http.Response, error) {
+	return p.Send(rawURL, newRequest().WithMethod(method.HEAD))
+}
+
+func (p *Pool) Post(rawURL, contentType string, body []byte) (*http.Response, error) {
+	request := newRequest().
+		WithMethod(method.POST).
+		WithHeader("Content-Type", contentType).
+		WithBodyBytes(body)
+
+	return p.Send(rawURL, request)
+}
+
+func (p *Pool) Delete(rawURL string) (*http.Response, error) {
+	return p.Send(rawURL, newRequest().WithMethod(method.DELETE))
+}
+
+// Send sends the request to the origin of the URL. The path of the request is replaced
+// by path and query of the URL. The response body MUST be closed, otherwise the
+// session won't be returned to the pool
+func (p *Pool) Send(rawURL string, request *http.Request) (*http.Response, error) {
+	target, path, err := parseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = request.Error(); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	breaker, middlewares := p.breaker, p.middlewares
+	p.mu.Unlock()
+
+	transport := RoundTripperFunc(func(request *http.Request) (*http.Response, error) {
+		if breaker == nil {
+			return p.send(target, request)
+		}
+
+		if err := breaker.Allow(target.addr); err != nil {
+			return nil, err
+		}
+
+		resp, err := p.send(target, request)
+		breaker.Done(target.addr, resp, err)
+
+		return resp, err
+	})
+
+	return chain(transport, middlewares).RoundTrip(request.WithPath(path))
+}
+
+// Use adds middlewares, wrapping every Send of the pool, the same way as Session.Use does
+func (p *Pool) Use(middlewares ...Middleware) {
+	p.mu.Lock()
+	// copy, so the slice captured by running requests is never modified
+	p.middlewares = append(p.middlewares[:len(p.middlewares):len(p.middlewares)], middlewares...)
+	p.mu.Unlock()
+}
+
+func (p *Pool) send(target origin, request *http.Request) (*http.Response, error) {
+	session, err := p.acquire(request.Context(), target, false)
+	if err != nil {
+		return nil, err
+	}
+
+	stale := session.reused
+	body, rewindable := bookmarkBody(request)
+	resp, err := session.Send(request)
+	if err != nil && stale && !session.responded && rewindable &&
+		isConnectionError(err) && method.IsIdempotent(request.Method) {
+		// the idle connection was most likely closed by the server in the meantime, so
+		// the request is sent once more over a new one
+		p.release(target, session, err)
+
+		if err = body.rewind(); err != nil {
+			return nil, err
+		}
+
+		if session, err = p.acquire(request.Context(), target, true); err != nil {
+			return nil, err
+		}
+
+		resp, err = session.Send(request)
+	}
+
+	if err != nil {
+		p.release(target, session, err)
+		return nil, err
+	}
+
+	resp.Body.OnClose(func(err error) {
+		p.release(target, session, err)
+	})
+
+	return resp, nil
+}
+
+// SetRateLimiter sets the limiter for all the requests, sent via the pool. Hosts are
+// identified by hostname:port, with the default port of the scheme if the URL has
+// none, e.g. https://example.com/ is example.com:443. So the same limiter applies to
+// sessions and pools alike
+func (p *Pool) SetRateLimiter(limiter *RateLimiter) {
+	p.mu.Lock()
+	p.limiter = limiter
+	p.mu.Unlock()
+}
+
+// SetCircuitBreaker sets the breaker for all the requests, sent via the pool. Hosts are
+// identified the same way, as for the rate limiter
+func (p *Pool) SetCircuitBreaker(breaker *CircuitBreaker) {
+	p.mu.Lock()
+	p.breaker = breaker
+	p.mu.Unlock()
+}
+
+// SetMetrics sets the metrics for all the requests, sent via the pool, including the
+// utilization of the pool itself. Nil disables it
+func (p *Pool) SetMetrics(m metrics.Metrics) {
+	p.mu.Lock()
+	p.metrics = m
+	p.mu.Unlock()
+}
+
+// Close closes all the idle sessions
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for target, sessions := range p.idle {
+		for _, session := range sessions {
+			_ = session.Close()
+		}
+
+		delete(p.idle, target)
+	}
+}
+
+// acquire returns an idle session of the origin, or dials a new one. If fresh is set,
+// idle sessions aren't considered
+func (p *Pool) acquire(ctx context.Context, target origin, fresh bool) (*Session, error) {
+	var session *Session
+
+	p.mu.Lock()
+	limiter, m := p.limiter, p.metrics
+	p.active[target]++
+	if sessions := p.idle[target]; len(sessions) > 0 && !fresh {
+		session = sessions[len(sessions)-1]
+		p.idle[target] = sessions[:len(sessions)-1]
+	}
+
+	active, idle := p.active[target], len(p.idle[target])
+	p.mu.Unlock()
+
+	if m != nil {
+		m.PoolUtilization(target.addr, active, idle)
+	}
+
+	if session == nil {
+		var err error
+		if session, err = p.dial(ctx, target); err != nil {
+			p.release(target, nil, err)
+			return nil, err
+		}
+	}
+
+	session.SetRateLimiter(limiter)
+	session.SetMetrics(m)
+
+	return session, nil
+}
+
+func (p *Pool) dial(ctx context.Context, target origin) (*Session, error) {
+	var config *tls.Config
+	if target.tls {
+		if config = p.tlsConfig; config == nil {
+			config = new(tls.Config)
+		}
+	}
+
+	connect := func(ctx context.Context, t *trace.ClientTrace) (net.Conn, error) {
+		return dial(ctx, target.addr, config, t)
+	}
+
+	conn, err := connect(ctx, trace.ContextClientTrace(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	session := NewSessionFromConn(conn, target.host, p.settings)
+	session.addr = target.addr
+	session.dial = connect
+
+	return session, nil
+}
+
+// release returns the session back to the pool, if the connection can be reused.
+// Otherwise, it's closed. The session is nil, if it failed to connect
+func (p *Pool) release(target origin, session *Session, err error) {
+	p.mu.Lock()
+	p.active[target]--
+	if p.active[target] <= 0 {
+		delete(p.active, target)
+	}
+
+	keep := session != nil && err == nil && isKeepAlive(session.response) &&
+		len(p.idle[target]) < maxIdlePerHost
+	if keep {
+		p.idle[target] = append(p.idle[target], session)
+	}
+
+	m, active, idle := p.metrics, p.active[target], len(p.idle[target])
+	p.mu.Unlock()
+
+	if !keep && session != nil {
+		_ = session.Close()
+	}
+
+	if m != nil {
+		m.PoolUtilization(target.addr, active, idle)
+	}
+}
+
+// isKeepAlive reports, whether the connection is persistent after the response. HTTP/1.1
+// connections are persistent unless closed explicitly, while HTTP/1.0 ones are closed
+// unless kept alive explicitly
+func isKeepAlive(resp *http.Response) bool {
+	keepAlive := resp.Proto == protocol.HTTP11
+
+	for _, value := range resp.Headers.Values("connection") {
+		for _, token := range strings.Split(value, ",") {
+			switch token = strings.TrimSpace(token); {
+			case strcomp.EqualFold(token, "close"):
+				return false
+			case strcomp.EqualFold(token, "keep-alive"):
+				keepAlive = true
+			}
+		}
+	}
+
+	return keepAlive
+}
+
+type origin struct {
+	tls                  bool
+	host, hostname, port string
+	// addr is hostname:port, with the default port of the scheme if it isn't set. It
+	// identifies the origin in the rate limiter, the circuit breaker and metrics, as
+	// sessions do
+	addr string
+}
+
+// parseURL returns the origin of the URL, and the path with the query
+func parseURL(rawURL string) (target origin, path string, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return target, "", err
+	}
+
+	switch u.Scheme {
+	case "http":
+		target.port = "80"
+	case "https":
+		target.tls = true
+		target.port = "443"
+	default:
+		return target, "", ErrUnsupportedScheme
+	}
+
+	target.host = u.Host
+	target.hostname = u.Hostname()
+	if port := u.Port(); len(port) > 0 {
+		target.port = port
+	}
+
+	target.addr = net.JoinHostPort(target.hostname, target.port)
+
+	path = u.EscapedPath()
+	if len(path) == 0 {
+		path = "/"
+	}
+
+	if len(u.RawQuery) > 0 {
+		path += "?" + u.RawQuery
+	}
+
+	return target, path, nil
+}
+
+func newRequest() *http.Request {
+	return http.NewRequest(headers.NewPreallocHeaders(preAllocHeaders))
+}
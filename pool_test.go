@@ -0,0 +1,181 @@
+package client
+
+import (
+	"bufio"
+	"crypto/tls"
+	"github.com/indigo-web/client/settings"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPool(t *testing.T) {
+	handler := nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		_, _ = w.Write([]byte(r.Host + " " + r.URL.RequestURI() + " " + string(body)))
+	})
+
+	t.Run("plain", func(t *testing.T) {
+		server := httptest.NewServer(handler)
+		defer server.Close()
+		pool := NewPool(settings.Default(), nil)
+		defer pool.Close()
+
+		for i := 0; i < 3; i++ {
+			resp, err := pool.Get(server.URL + "/hello?a=b")
+			require.NoError(t, err)
+			require.Equal(t, 200, int(resp.Code))
+			body, err := resp.Body.Full()
+			require.NoError(t, err)
+			require.Equal(t, server.Listener.Addr().String()+" /hello?a=b ", string(body))
+			require.NoError(t, resp.Body.Close())
+		}
+
+		require.Len(t, pool.idle, 1)
+		for _, sessions := range pool.idle {
+			require.Len(t, sessions, 1)
+		}
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		server := httptest.NewTLSServer(handler)
+		defer server.Close()
+		pool := NewPool(settings.Default(), &tls.Config{InsecureSkipVerify: true})
+		defer pool.Close()
+
+		resp, err := pool.Post(server.URL, "text/plain", []byte("payload"))
+		require.NoError(t, err)
+		require.Equal(t, "POST", resp.Headers.Value("x-method"))
+		body, err := resp.Body.Full()
+		require.NoError(t, err)
+		require.Equal(t, server.Listener.Addr().String()+" / payload", string(body))
+		require.NoError(t, resp.Body.Close())
+	})
+
+	t.Run("head", func(t *testing.T) {
+		server := httptest.NewServer(handler)
+		defer server.Close()
+		pool := NewPool(settings.Default(), nil)
+		defer pool.Close()
+
+		for i := 0; i < 2; i++ {
+			resp, err := pool.Head(server.URL)
+			require.NoError(t, err)
+			require.Equal(t, "HEAD", resp.Headers.Value("x-method"))
+			body, err := resp.Body.Full()
+			require.NoError(t, err)
+			require.Empty(t, body)
+			require.NoError(t, resp.Body.Close())
+		}
+	})
+
+	t.Run("unsupported scheme", func(t *testing.T) {
+		_, err := NewPool(settings.Default(), nil).Get("ftp://localhost/")
+		require.ErrorIs(t, err, ErrUnsupportedScheme)
+	})
+
+	t.Run("url", func(t *testing.T) {
+		for rawURL, addr := range map[string]string{
+			"http://example.com":        "example.com:80",
+			"https://example.com/a?b=c": "example.com:443",
+			"https://example.com:8443/": "example.com:8443",
+			"http://[::1]/":             "[::1]:80",
+		} {
+			target, _, err := parseURL(rawURL)
+			require.NoError(t, err)
+			require.Equal(t, addr, target.addr, rawURL)
+		}
+	})
+
+	t.Run("http 1.0", func(t *testing.T) {
+		for response, pooled := range map[string]bool{
+			"HTTP/1.0 200 OK\r\nContent-Length: 2\r\n\r\nhi":                           false,
+			"HTTP/1.0 200 OK\r\nConnection: keep-alive\r\nContent-Length: 2\r\n\r\nhi": true,
+		} {
+			addr, _ := serveRaw(t, response, false)
+			pool := NewPool(settings.Default(), nil)
+
+			resp, err := pool.Get("http://" + addr + "/")
+			require.NoError(t, err)
+			body, err := resp.Body.Full()
+			require.NoError(t, err)
+			require.Equal(t, "hi", string(body))
+			require.NoError(t, resp.Body.Close())
+
+			var idle int
+			for _, sessions := range pool.idle {
+				idle += len(sessions)
+			}
+
+			require.Equal(t, pooled, idle == 1, response)
+			pool.Close()
+		}
+	})
+
+	t.Run("stale idle connection", func(t *testing.T) {
+		addr, accepted := serveRaw(t, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nhi", true)
+		pool := NewPool(settings.Default(), nil)
+		defer pool.Close()
+
+		for i := 0; i < 2; i++ {
+			resp, err := pool.Get("http://" + addr + "/")
+			require.NoError(t, err)
+			body, err := resp.Body.Full()
+			require.NoError(t, err)
+			require.Equal(t, "hi", string(body))
+			require.NoError(t, resp.Body.Close())
+		}
+
+		require.Equal(t, int32(2), accepted.Load())
+
+		_, err := pool.Post("http://"+addr+"/", "text/plain", []byte("not idempotent"))
+		require.Error(t, err)
+	})
+}
+
+// serveRaw responds to every request with the same raw response. If closeIdle is set,
+// connections are closed after the first response, but the client isn't told so
+func serveRaw(t *testing.T, response string, closeIdle bool) (string, *atomic.Int32) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
+	accepted := new(atomic.Int32)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			accepted.Add(1)
+
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+
+				for {
+					req, err := nethttp.ReadRequest(reader)
+					if err != nil {
+						return
+					}
+
+					_, _ = io.Copy(io.Discard, req.Body)
+					if _, err = conn.Write([]byte(response)); err != nil || closeIdle {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), accepted
+}
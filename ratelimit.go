@@ -0,0 +1,228 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrRateLimited = errors.New("rate limit is exceeded")
+
+// LimiterMode defines, what happens to the request, if the limit is exceeded
+type LimiterMode uint8
+
+const (
+	// LimitWait blocks the request until it fits the limit, or its context is done
+	LimitWait LimiterMode = iota
+	// LimitFailFast returns ErrRateLimited immediately
+	LimitFailFast
+)
+
+// defaultMaxPause caps the pause, requested by the server, unless set explicitly
+const defaultMaxPause = 30 * time.Second
+
+// Limit is a rate of the token bucket. Zero rate means there is no limit
+type Limit struct {
+	// Rate is the number of requests per second, allowed in average
+	Rate float64
+	// Burst is the maximal number of requests, allowed at once. Values less than 1 are
+	// treated as 1
+	Burst int
+}
+
+// RateLimiter limits the rate of requests both globally and per each host, using
+// token buckets. It's safe to share a single limiter between multiple sessions and pools
+type RateLimiter struct {
+	mu         sync.Mutex
+	mode       LimiterMode
+	global     *bucket
+	perHost    Limit
+	hostLimits map[string]Limit
+	hosts      map[string]*bucket
+	paused     map[string]time.Time
+	maxPause   time.Duration
+}
+
+func NewRateLimiter(mode LimiterMode) *RateLimiter {
+	return &RateLimiter{
+		mode:       mode,
+		hostLimits: make(map[string]Limit),
+		hosts:      make(map[string]*bucket),
+		paused:     make(map[string]time.Time),
+		maxPause:   defaultMaxPause,
+	}
+}
+
+// SetGlobal sets the limit for all the requests, regardless of their hosts
+func (r *RateLimiter) SetGlobal(limit Limit) *RateLimiter {
+	r.mu.Lock()
+	r.global = newBucket(limit, time.Now())
+	r.mu.Unlock()
+
+	return r
+}
+
+// SetPerHost sets the limit, applied to each host separately, unless the host has its
+// own limit, set by SetHost
+func (r *RateLimiter) SetPerHost(limit Limit) *RateLimiter {
+	r.mu.Lock()
+	r.perHost = limit
+	clear(r.hosts)
+	r.mu.Unlock()
+
+	return r
+}
+
+// SetHost sets the limit for the host. The host must be hostname:port, e.g.
+// example.com:443, as both sessions and pools identify hosts this way
+func (r *RateLimiter) SetHost(host string, limit Limit) *RateLimiter {
+	r.mu.Lock()
+	r.hostLimits[host] = limit
+	delete(r.hosts, host)
+	r.mu.Unlock()
+
+	return r
+}
+
+// SetMaxPause caps pauses, requested via Pause. By default, it's 30 seconds
+func (r *RateLimiter) SetMaxPause(d time.Duration) *RateLimiter {
+	r.mu.Lock()
+	r.maxPause = d
+	r.mu.Unlock()
+
+	return r
+}
+
+// Wait takes a token for the request to the host. Depending on the mode, it either
+// blocks until the token is available, or returns ErrRateLimited
+func (r *RateLimiter) Wait(ctx context.Context, host string) error {
+	for {
+		delay := r.take(host, time.Now())
+		if delay == 0 {
+			return nil
+		}
+
+		if r.mode == LimitFailFast {
+			return ErrRateLimited
+		}
+
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// Pause suspends requests to the host for the duration, e.g. as requested via the
+// Retry-After header. The duration is capped by SetMaxPause
+func (r *RateLimiter) Pause(host string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if until := time.Now().Add(min(d, r.maxPause)); until.After(r.paused[host]) {
+		r.paused[host] = until
+	}
+}
+
+// take consumes a token from both global and host buckets, if both have one. Otherwise,
+// nothing is consumed, and the time to wait for is returned
+func (r *RateLimiter) take(host string, now time.Time) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if until, found := r.paused[host]; found {
+		if now.Before(until) {
+			return until.Sub(now)
+		}
+
+		delete(r.paused, host)
+	}
+
+	hostBucket := r.host(host, now)
+	delay := max(r.global.delay(now), hostBucket.delay(now))
+	if delay == 0 {
+		r.global.take()
+		hostBucket.take()
+	}
+
+	return delay
+}
+
+// host returns the bucket of the host, or nil if the host isn't limited
+func (r *RateLimiter) host(host string, now time.Time) *bucket {
+	if b, found := r.hosts[host]; found {
+		return b
+	}
+
+	limit, found := r.hostLimits[host]
+	if !found {
+		limit = r.perHost
+	}
+
+	b := newBucket(limit, now)
+	if b != nil {
+		r.hosts[host] = b
+	}
+
+	return b
+}
+
+// bucket is a token bucket. Nil bucket is unlimited
+type bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(limit Limit, now time.Time) *bucket {
+	if limit.Rate <= 0 {
+		return nil
+	}
+
+	burst := float64(max(limit.Burst, 1))
+
+	return &bucket{
+		rate:   limit.Rate,
+		burst:  burst,
+		tokens: burst,
+		last:   now,
+	}
+}
+
+// delay refills the bucket and returns the time, after which a token will be available
+func (b *bucket) delay(now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		return 0
+	}
+
+	delay := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	// rounding might give zero, which would mean the token is available
+	return max(delay, time.Nanosecond)
+}
+
+func (b *bucket) take() {
+	if b != nil {
+		b.tokens--
+	}
+}
+
+// sleep waits for the duration, or until the context is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"github.com/indigo-web/client/settings"
+	"github.com/stretchr/testify/require"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("fail fast", func(t *testing.T) {
+		limiter := NewRateLimiter(LimitFailFast).SetPerHost(Limit{Rate: 1, Burst: 2})
+		require.NoError(t, limiter.Wait(ctx, "a"))
+		require.NoError(t, limiter.Wait(ctx, "a"))
+		require.ErrorIs(t, limiter.Wait(ctx, "a"), ErrRateLimited)
+		// other hosts have their own buckets
+		require.NoError(t, limiter.Wait(ctx, "b"))
+	})
+
+	t.Run("global", func(t *testing.T) {
+		limiter := NewRateLimiter(LimitFailFast).
+			SetGlobal(Limit{Rate: 1, Burst: 1}).
+			SetHost("a", Limit{Rate: 100, Burst: 10})
+		require.NoError(t, limiter.Wait(ctx, "a"))
+		require.ErrorIs(t, limiter.Wait(ctx, "b"), ErrRateLimited)
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		limiter := NewRateLimiter(LimitWait).SetGlobal(Limit{Rate: 100})
+		start := time.Now()
+		for i := 0; i < 5; i++ {
+			require.NoError(t, limiter.Wait(ctx, "a"))
+		}
+
+		require.GreaterOrEqual(t, time.Since(start), 35*time.Millisecond)
+	})
+
+	t.Run("context", func(t *testing.T) {
+		limiter := NewRateLimiter(LimitWait).SetGlobal(Limit{Rate: 0.001})
+		require.NoError(t, limiter.Wait(ctx, "a"))
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, limiter.Wait(ctx, "a"), context.DeadlineExceeded)
+	})
+
+	t.Run("pause", func(t *testing.T) {
+		limiter := NewRateLimiter(LimitFailFast).SetMaxPause(time.Hour)
+		limiter.Pause("a", time.Minute)
+		require.ErrorIs(t, limiter.Wait(ctx, "a"), ErrRateLimited)
+		require.NoError(t, limiter.Wait(ctx, "b"))
+
+		limiter.SetMaxPause(0)
+		limiter.Pause("b", time.Minute)
+		require.NoError(t, limiter.Wait(ctx, "b"))
+	})
+
+	t.Run("session", func(t *testing.T) {
+		server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(nethttp.StatusTooManyRequests)
+		}))
+		defer server.Close()
+
+		session, err := NewSession(server.Listener.Addr().String())
+		require.NoError(t, err)
+		defer session.Close()
+		session.SetRateLimiter(NewRateLimiter(LimitFailFast))
+
+		resp, err := session.Send(session.GET("/"))
+		require.NoError(t, err)
+		require.Equal(t, 429, int(resp.Code))
+		_, err = session.Send(session.GET("/"))
+		require.ErrorIs(t, err, ErrRateLimited)
+	})
+
+	t.Run("shared by session and pool", func(t *testing.T) {
+		server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+		defer server.Close()
+
+		host := server.Listener.Addr().String()
+		limiter := NewRateLimiter(LimitFailFast).SetHost(host, Limit{Rate: 0.001, Burst: 1})
+		session, err := NewSession(host)
+		require.NoError(t, err)
+		defer session.Close()
+		session.SetRateLimiter(limiter)
+		pool := NewPool(settings.Default(), nil)
+		defer pool.Close()
+		pool.SetRateLimiter(limiter)
+
+		resp, err := session.Send(session.GET("/"))
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		_, err = pool.Get(server.URL)
+		require.ErrorIs(t, err, ErrRateLimited)
+	})
+}
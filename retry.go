@@ -0,0 +1,204 @@
+package client
+
+import (
+	"errors"
+	"github.com/indigo-web/client/http"
+	"github.com/indigo-web/client/http/method"
+	"github.com/indigo-web/client/http/status"
+	"github.com/indigo-web/client/settings"
+	"io"
+	"math/rand/v2"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// sendWithRetry sends the request, repeating it if the retry policy allows to. The
+// connection is re-established if it's broken, and the body is rewound before each
+// attempt. Requests with bodies, which can't be rewound, are sent only once
+func (s *Session) sendWithRetry(request *http.Request) (*http.Response, error) {
+	policy := s.settings.Retry
+	if policy.MaxAttempts < 2 || !isRetryable(policy, request.Method) {
+		return s.send(request)
+	}
+
+	body, ok := bookmarkBody(request)
+	if !ok {
+		return s.send(request)
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := s.send(request)
+		if attempt >= policy.MaxAttempts {
+			return resp, err
+		}
+
+		switch {
+		case err != nil:
+			if !isConnectionError(err) || s.dial == nil {
+				return nil, err
+			}
+		case !hasStatus(policy.Statuses, resp.Code):
+			return resp, nil
+		case !isKeepAlive(resp) && s.dial == nil:
+			return resp, nil
+		}
+
+		if sleepErr := sleep(request.Context(), retryDelay(policy, attempt, resp)); sleepErr != nil {
+			return nil, sleepErr
+		}
+
+		if err != nil || !isKeepAlive(resp) {
+			if err = s.reconnect(request); err != nil {
+				return nil, err
+			}
+		}
+
+		if err = body.rewind(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// reconnect replaces the connection with a new one. The response is reset, so the rest
+// of the previous response isn't read from the new connection
+func (s *Session) reconnect(request *http.Request) error {
+	conn, err := s.dial(request.Context(), s.traceOf(request))
+	if err != nil {
+		return err
+	}
+
+	_ = s.client.Close()
+	s.client.Reset(conn)
+	s.response.Body.InitEmpty(s.response)
+	s.reused = false
+
+	if s.metrics != nil {
+		s.metrics.Reconnect(s.addr)
+	}
+
+	return nil
+}
+
+// bodyBookmark remembers the position of the streamed body, so it can be sent again
+type bodyBookmark struct {
+	seeker io.Seeker
+	offset int64
+}
+
+// bookmarkBody returns the bookmark of the request body. False is returned, if the body
+// is streamed from a reader, which can't seek
+func bookmarkBody(request *http.Request) (bodyBookmark, bool) {
+	var seeker io.Seeker
+
+	switch {
+	case request.File != nil:
+		seeker = request.File
+	case request.Reader != nil:
+		var ok bool
+		if seeker, ok = request.Reader.(io.Seeker); !ok {
+			return bodyBookmark{}, false
+		}
+	default:
+		// the body is either empty or passed as bytes, so it doesn't need any rewinding
+		return bodyBookmark{}, true
+	}
+
+	offset, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return bodyBookmark{}, false
+	}
+
+	return bodyBookmark{seeker: seeker, offset: offset}, true
+}
+
+func (b bodyBookmark) rewind() error {
+	if b.seeker == nil {
+		return nil
+	}
+
+	_, err := b.seeker.Seek(b.offset, io.SeekStart)
+	return err
+}
+
+func isRetryable(policy settings.Retry, m method.Method) bool {
+	if len(policy.Methods) == 0 {
+		return method.IsIdempotent(m)
+	}
+
+	for _, allowed := range policy.Methods {
+		if allowed == m {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasStatus(statuses []status.Code, code status.Code) bool {
+	for _, s := range statuses {
+		if s == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isConnectionError reports, whether the error is caused by the connection itself,
+// including timeouts, rather than by the malformed or too large response
+func isConnectionError(err error) bool {
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE),
+		errors.Is(err, os.ErrDeadlineExceeded), errors.As(err, &netErr):
+		return true
+	}
+
+	return false
+}
+
+// retryDelay returns the delay before the next attempt. If the server asked to wait via
+// the Retry-After header, it's respected (but capped). Otherwise, the backoff is used
+func retryDelay(policy settings.Retry, attempt int, resp *http.Response) time.Duration {
+	if resp == nil {
+		return backoff(policy, attempt)
+	}
+
+	delay, ok := resp.RetryAfter()
+	if !ok {
+		return backoff(policy, attempt)
+	}
+
+	return min(delay, retryAfterCap(policy))
+}
+
+func retryAfterCap(policy settings.Retry) time.Duration {
+	if policy.MaxRetryAfter > 0 {
+		return policy.MaxRetryAfter
+	}
+
+	return policy.MaxDelay
+}
+
+// backoff returns the delay before the next attempt. It grows exponentially, and is
+// randomized in range [delay/2, delay], so multiple clients don't retry simultaneously
+func backoff(policy settings.Retry, attempt int) time.Duration {
+	delay := policy.BaseDelay
+	for i := 1; i < attempt && (policy.MaxDelay <= 0 || delay < policy.MaxDelay); i++ {
+		delay *= 2
+	}
+
+	if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+
+	if delay <= 0 {
+		return 0
+	}
+
+	return delay/2 + rand.N(delay/2+1)
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"github.com/indigo-web/client/http/method"
+	"github.com/indigo-web/client/settings"
+	"github.com/stretchr/testify/require"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	newSession := func(t *testing.T, server *httptest.Server) *Session {
+		s := settings.Default()
+		s.Retry = settings.DefaultRetry()
+		s.Retry.BaseDelay = time.Millisecond
+		session, err := NewSessionWithSettings(server.Listener.Addr().String(), s)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = session.Close()
+		})
+
+		return session
+	}
+
+	t.Run("retryable status", func(t *testing.T) {
+		var attempts atomic.Int32
+		server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			body, _ := io.ReadAll(r.Body)
+			if attempts.Add(1) < 3 {
+				w.WriteHeader(nethttp.StatusServiceUnavailable)
+				_, _ = w.Write([]byte("try again"))
+				return
+			}
+
+			_, _ = w.Write(body)
+		}))
+		defer server.Close()
+
+		session := newSession(t, server)
+		resp, err := session.Send(session.PUT("/").WithBodyFrom(strings.NewReader("payload")))
+		require.NoError(t, err)
+		require.Equal(t, 200, int(resp.Code))
+		body, err := resp.Body.Full()
+		require.NoError(t, err)
+		require.Equal(t, "payload", string(body))
+		require.Equal(t, int32(3), attempts.Load())
+	})
+
+	t.Run("broken connection", func(t *testing.T) {
+		var attempts atomic.Int32
+		server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			if attempts.Add(1) == 1 {
+				conn, _, err := w.(nethttp.Hijacker).Hijack()
+				require.NoError(t, err)
+				_ = conn.Close()
+				return
+			}
+
+			_, _ = w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+
+		session := newSession(t, server)
+		resp, err := session.Send(session.GET("/"))
+		require.NoError(t, err)
+		body, err := resp.Body.Full()
+		require.NoError(t, err)
+		require.Equal(t, "ok", string(body))
+		require.Equal(t, int32(2), attempts.Load())
+	})
+
+	t.Run("non-idempotent", func(t *testing.T) {
+		var attempts atomic.Int32
+		server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			attempts.Add(1)
+			w.WriteHeader(nethttp.StatusBadGateway)
+		}))
+		defer server.Close()
+
+		session := newSession(t, server)
+		resp, err := session.Send(session.POST("/").WithBody("payload"))
+		require.NoError(t, err)
+		require.Equal(t, 502, int(resp.Code))
+		require.Equal(t, int32(1), attempts.Load())
+	})
+
+	t.Run("attempts exhausted", func(t *testing.T) {
+		var attempts atomic.Int32
+		server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			attempts.Add(1)
+			w.WriteHeader(nethttp.StatusGatewayTimeout)
+		}))
+		defer server.Close()
+
+		session := newSession(t, server)
+		resp, err := session.Send(session.GET("/"))
+		require.NoError(t, err)
+		require.Equal(t, 504, int(resp.Code))
+		require.Equal(t, int32(3), attempts.Load())
+	})
+
+	t.Run("retry-after", func(t *testing.T) {
+		var attempts atomic.Int32
+		server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			if attempts.Add(1) == 1 {
+				w.Header().Set("Retry-After", "3600")
+				w.WriteHeader(nethttp.StatusTooManyRequests)
+				return
+			}
+
+			_, _ = w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+
+		session := newSession(t, server)
+		session.settings.Retry.MaxRetryAfter = 50 * time.Millisecond
+		start := time.Now()
+		resp, err := session.Send(session.GET("/"))
+		require.NoError(t, err)
+		require.Equal(t, 200, int(resp.Code))
+		require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+		require.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("idempotent methods", func(t *testing.T) {
+		require.True(t, method.IsIdempotent(method.PUT))
+		require.False(t, method.IsIdempotent(method.PATCH))
+	})
+}
@@ -1,11 +1,83 @@
 package settings
 
+import (
+	"github.com/indigo-web/client/http/method"
+	"github.com/indigo-web/client/http/status"
+	"time"
+)
+
 type Settings struct {
-	Body Body
+	Body    Body
+	Request Request
+	Retry   Retry
 }
 
 type (
+	// Body limits the response body. Zero value of any limit means there is no limit
 	Body struct {
+		// MaxChunkSize is the maximal length of a single chunk of a chunked body
 		MaxChunkSize int64
+		// MaxSize is the maximal size of the body, as it's transferred
+		MaxSize int64
+		// MaxDecodedSize is the maximal size of the body after it's decompressed. This
+		// protects from so-called zip bombs. Slice-based decoders also may not receive
+		// more than that, as they buffer the whole input
+		MaxDecodedSize int64
+	}
+
+	Request struct {
+		// ChunkSize is the maximal size of a single piece of the request body, streamed
+		// from a file or a reader. Memory used for uploading is bounded by this value.
+		// Zero means the default size
+		ChunkSize int
+	}
+
+	// Retry is a policy of retrying failed requests. It's disabled by default, see
+	// DefaultRetry for recommended values
+	Retry struct {
+		// MaxAttempts is the maximal number of attempts, including the first one. Values
+		// less than 2 disable retrying
+		MaxAttempts int
+		// Statuses are response codes, on which the request is retried. Connection errors
+		// and timeouts are retried regardless of them
+		Statuses []status.Code
+		// Methods are methods, which are allowed to be retried. If empty, only idempotent
+		// ones are
+		Methods []method.Method
+		// BaseDelay is the delay before the first retry. Every next one is doubled, and
+		// randomized by the jitter
+		BaseDelay time.Duration
+		// MaxDelay caps the delay between attempts
+		MaxDelay time.Duration
+		// MaxRetryAfter caps the delay, requested by the server via the Retry-After header.
+		// If zero, MaxDelay is used instead
+		MaxRetryAfter time.Duration
 	}
 )
+
+func Default() Settings {
+	return Settings{
+		Body: Body{
+			MaxChunkSize:   16 * 1024 * 1024,
+			MaxSize:        256 * 1024 * 1024,
+			MaxDecodedSize: 256 * 1024 * 1024,
+		},
+		Request: Request{
+			ChunkSize: 32 * 1024,
+		},
+	}
+}
+
+// DefaultRetry returns a policy, retrying up to 3 times on connection errors, timeouts
+// and 429, 502, 503 or 504 responses
+func DefaultRetry() Retry {
+	return Retry{
+		MaxAttempts: 3,
+		Statuses: []status.Code{
+			status.TooManyRequests, status.BadGateway, status.ServiceUnavailable, status.GatewayTimeout,
+		},
+		BaseDelay:     100 * time.Millisecond,
+		MaxDelay:      5 * time.Second,
+		MaxRetryAfter: 30 * time.Second,
+	}
+}
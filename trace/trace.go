@@ -0,0 +1,56 @@
+package trace
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/indigo-web/client/http"
+	"net"
+)
+
+// ClientTrace is a set of hooks, called at the stages of the request. Any of them may
+// be nil. Hooks are called synchronously from the goroutine, sending the request, so
+// they must not block for long
+type ClientTrace struct {
+	// DNSStart is called before the host is resolved. It isn't called, if the host is
+	// an IP address already
+	DNSStart func(host string)
+	// DNSDone is called after the host is resolved
+	DNSDone func(addrs []net.IPAddr, err error)
+	// ConnectStart is called before dialing each of resolved addresses
+	ConnectStart func(network, addr string)
+	// ConnectDone is called after the dial is completed, successfully or not
+	ConnectDone func(network, addr string, err error)
+	// TLSHandshakeStart is called before the TLS handshake
+	TLSHandshakeStart func()
+	// TLSHandshakeDone is called after the TLS handshake is completed, successfully or not
+	TLSHandshakeDone func(state tls.ConnectionState, err error)
+	// GotConn is called before the request is written. Reused reports, whether the
+	// connection has already served previous requests
+	GotConn func(reused bool)
+	// WroteHeaders is called after the request line and headers are written. If the
+	// body is passed as bytes, it's written together with them
+	WroteHeaders func()
+	// WroteRequest is called after the whole request is written, successfully or not
+	WroteRequest func(err error)
+	// GotFirstResponseByte is called, once the first byte of the response is received
+	GotFirstResponseByte func()
+	// GotHeaders is called, once the response headers are parsed
+	GotHeaders func(resp *http.Response)
+	// BodyDone is called, once the response body is received completely, or reading
+	// it fails. It isn't called, if the body is never read nor closed
+	BodyDone func(err error)
+}
+
+type traceKey struct{}
+
+// WithClientTrace returns the context, carrying the trace. Requests with such a context
+// report to the trace instead of the one, set on the session
+func WithClientTrace(ctx context.Context, trace *ClientTrace) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+// ContextClientTrace returns the trace of the context, or nil if there is none
+func ContextClientTrace(ctx context.Context) *ClientTrace {
+	trace, _ := ctx.Value(traceKey{}).(*ClientTrace)
+	return trace
+}
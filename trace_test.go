@@ -0,0 +1,127 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/indigo-web/client/http"
+	"github.com/indigo-web/client/http/method"
+	"github.com/indigo-web/client/settings"
+	"github.com/indigo-web/client/trace"
+	"github.com/stretchr/testify/require"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	handler := nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		_, _ = w.Write([]byte("hello"))
+	})
+
+	newTrace := func(events *[]string) *trace.ClientTrace {
+		return &trace.ClientTrace{
+			DNSStart: func(host string) {
+				*events = append(*events, "dns start "+host)
+			},
+			DNSDone: func(_ []net.IPAddr, err error) {
+				*events = append(*events, "dns done")
+			},
+			ConnectDone: func(_, _ string, err error) {
+				if err == nil {
+					*events = append(*events, "connected")
+				}
+			},
+			TLSHandshakeStart: func() {
+				*events = append(*events, "tls start")
+			},
+			TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+				require.NoError(t, err)
+				require.True(t, state.HandshakeComplete)
+				*events = append(*events, "tls done")
+			},
+			GotConn: func(reused bool) {
+				if reused {
+					*events = append(*events, "reused conn")
+				} else {
+					*events = append(*events, "new conn")
+				}
+			},
+			WroteHeaders: func() {
+				*events = append(*events, "wrote headers")
+			},
+			WroteRequest: func(err error) {
+				require.NoError(t, err)
+				*events = append(*events, "wrote request")
+			},
+			GotFirstResponseByte: func() {
+				*events = append(*events, "first byte")
+			},
+			GotHeaders: func(resp *http.Response) {
+				*events = append(*events, "headers "+strconv.Itoa(int(resp.Code)))
+			},
+			BodyDone: func(err error) {
+				require.NoError(t, err)
+				*events = append(*events, "body done")
+			},
+		}
+	}
+
+	exchange := []string{
+		"wrote headers", "wrote request", "first byte", "headers 200", "body done",
+	}
+
+	t.Run("pool", func(t *testing.T) {
+		server := httptest.NewTLSServer(handler)
+		defer server.Close()
+		pool := NewPool(settings.Default(), &tls.Config{InsecureSkipVerify: true})
+		defer pool.Close()
+
+		_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+		require.NoError(t, err)
+		url := "https://localhost:" + port
+
+		var events []string
+		ctx := trace.WithClientTrace(context.Background(), newTrace(&events))
+
+		for i := 0; i < 2; i++ {
+			resp, err := pool.Send(url, newRequest().WithMethod(method.GET).WithContext(ctx))
+			require.NoError(t, err)
+			body, err := resp.Body.Full()
+			require.NoError(t, err)
+			require.Equal(t, "hello", string(body))
+			require.NoError(t, resp.Body.Close())
+		}
+
+		want := []string{"dns start localhost", "dns done", "connected", "tls start", "tls done", "new conn"}
+		want = append(want, exchange...)
+		want = append(want, "reused conn")
+		want = append(want, exchange...)
+		require.Equal(t, want, events)
+	})
+
+	t.Run("session", func(t *testing.T) {
+		server := httptest.NewServer(handler)
+		defer server.Close()
+		session, err := NewSession(strings.TrimPrefix(server.URL, "http://"))
+		require.NoError(t, err)
+		defer session.Close()
+
+		var sessionEvents, requestEvents []string
+		session.SetTrace(newTrace(&sessionEvents))
+
+		resp, err := session.Send(session.GET("/"))
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		ctx := trace.WithClientTrace(context.Background(), newTrace(&requestEvents))
+		resp, err = session.Send(session.HEAD("/").WithContext(ctx))
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		require.Equal(t, append([]string{"new conn"}, exchange...), sessionEvents)
+		require.Equal(t, append([]string{"reused conn"}, exchange...), requestEvents)
+	})
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"github.com/indigo-web/client/http/stdhttp"
+	"io"
+	nethttp "net/http"
+	"sync"
+)
+
+// Transport implements net/http.RoundTripper over the pool, so the client may be plugged
+// into the code, expecting *net/http.Client. Response bodies MUST be closed, otherwise
+// connections aren't returned to the pool
+type Transport struct {
+	pool *Pool
+}
+
+// NewTransport returns a transport over the pool. If pool is nil, the default one is used
+func NewTransport(pool *Pool) *Transport {
+	if pool == nil {
+		pool = defaultPool
+	}
+
+	return &Transport{
+		pool: pool,
+	}
+}
+
+func (t *Transport) RoundTrip(req *nethttp.Request) (*nethttp.Response, error) {
+	request := stdhttp.FromRequest(newRequest(), req)
+	resp, err := t.pool.Send(req.URL.String(), request)
+	closeRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return stdhttp.ToResponse(resp, req), nil
+}
+
+// SessionTransport implements net/http.RoundTripper over a single session. All the
+// requests are sent to the session's host, regardless of their URLs. As the session
+// can't process multiple requests at once, they're serialized: the next request waits
+// until the body of the previous response is closed
+type SessionTransport struct {
+	mu      sync.Mutex
+	session *Session
+}
+
+func NewSessionTransport(session *Session) *SessionTransport {
+	return &SessionTransport{
+		session: session,
+	}
+}
+
+func (s *SessionTransport) RoundTrip(req *nethttp.Request) (*nethttp.Response, error) {
+	s.mu.Lock()
+	request := stdhttp.FromRequest(s.session.request.WithClear(), req)
+	resp, err := s.session.Send(request)
+	closeRequestBody(req)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+
+	netResp := stdhttp.ToResponse(resp, req)
+	netResp.Body = &unlockingBody{
+		ReadCloser: netResp.Body,
+		unlock:     s.mu.Unlock,
+	}
+
+	return netResp, nil
+}
+
+// unlockingBody releases the session, once the body is closed
+type unlockingBody struct {
+	io.ReadCloser
+	unlock func()
+	once   sync.Once
+}
+
+func (u *unlockingBody) Close() error {
+	err := u.ReadCloser.Close()
+	u.once.Do(u.unlock)
+
+	return err
+}
+
+func closeRequestBody(req *nethttp.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
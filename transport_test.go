@@ -0,0 +1,111 @@
+package client
+
+import (
+	"compress/gzip"
+	"github.com/indigo-web/client/settings"
+	"github.com/stretchr/testify/require"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		switch r.URL.Path {
+		case "/echo":
+			body, _ := io.ReadAll(r.Body)
+			w.Header()["X-Values"] = r.Header["X-Values"]
+			_, _ = w.Write([]byte(r.Method + " " + r.URL.RawQuery + " " + string(body)))
+		case "/gzip":
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			_, _ = gz.Write([]byte("compressed"))
+			_ = gz.Close()
+		case "/trailers":
+			w.Header().Set("Trailer", "Checksum")
+			_, _ = w.Write([]byte("data"))
+			w.(nethttp.Flusher).Flush()
+			w.Header().Set("Checksum", "abc")
+		case "/id":
+			id := r.URL.Query().Get("id")
+			w.Header().Set("X-Id", id)
+			w.Header().Set("Trailer", "Checksum")
+			_, _ = w.Write([]byte("data"))
+			w.(nethttp.Flusher).Flush()
+			w.Header().Set("Checksum", strings.ToLower(id))
+		}
+	}))
+	defer server.Close()
+
+	pool := NewPool(settings.Default(), nil)
+	defer pool.Close()
+
+	for name, transport := range map[string]func(t *testing.T) nethttp.RoundTripper{
+		"pool": func(*testing.T) nethttp.RoundTripper {
+			return NewTransport(pool)
+		},
+		"session": func(t *testing.T) nethttp.RoundTripper {
+			session, err := NewSession(server.Listener.Addr().String())
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				_ = session.Close()
+			})
+
+			return NewSessionTransport(session)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client := &nethttp.Client{Transport: transport(t)}
+
+			req, err := nethttp.NewRequest(nethttp.MethodPost, server.URL+"/echo?a=b", strings.NewReader("payload"))
+			require.NoError(t, err)
+			req.Header.Add("X-Values", "1")
+			req.Header.Add("X-Values", "2")
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			require.Equal(t, 200, resp.StatusCode)
+			require.Equal(t, "200 OK", resp.Status)
+			require.Equal(t, []string{"1", "2"}, resp.Header.Values("X-Values"))
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+			require.Equal(t, "POST a=b payload", string(body))
+
+			resp, err = client.Get(server.URL + "/gzip")
+			require.NoError(t, err)
+			body, err = io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+			require.Equal(t, "compressed", string(body))
+			require.True(t, resp.Uncompressed)
+			require.Empty(t, resp.Header.Get("Content-Encoding"))
+
+			resp, err = client.Get(server.URL + "/trailers")
+			require.NoError(t, err)
+			require.Contains(t, resp.Trailer, "Checksum")
+			body, err = io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+			require.Equal(t, "data", string(body))
+			require.Equal(t, "abc", resp.Trailer.Get("Checksum"))
+
+			// the connection is reused, so the first response must not depend on its buffers
+			first, err := client.Get(server.URL + "/id?id=AAAAAAAA")
+			require.NoError(t, err)
+			_, err = io.ReadAll(first.Body)
+			require.NoError(t, err)
+			require.NoError(t, first.Body.Close())
+			second, err := client.Get(server.URL + "/id?id=BBBBBBBB")
+			require.NoError(t, err)
+			_, err = io.ReadAll(second.Body)
+			require.NoError(t, err)
+			require.NoError(t, second.Body.Close())
+			require.Equal(t, "BBBBBBBB", second.Header.Get("X-Id"))
+			require.Equal(t, "bbbbbbbb", second.Trailer.Get("Checksum"))
+			require.Equal(t, "AAAAAAAA", first.Header.Get("X-Id"))
+			require.Equal(t, "aaaaaaaa", first.Trailer.Get("Checksum"))
+		})
+	}
+}